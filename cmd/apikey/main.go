@@ -0,0 +1,142 @@
+// Command apikey generates and hashes the API keys of the server.ApiKeyStore.
+//
+//	apikey generate --user billing --roles admin --expires-in 2160h
+//	apikey hash --user legacy --lookup-secret "$SECRET" < key.txt
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/http/server"
+	"github.com/spf13/cobra"
+)
+
+type entryFlags struct {
+	userID       string
+	roles        []string
+	notBefore    string
+	expiresIn    time.Duration
+	lookupSecret string
+}
+
+func (f *entryFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.userID, "user", "", "user ID of the key (required)")
+	cmd.Flags().StringSliceVar(&f.roles, "roles", nil, "roles of the key")
+	cmd.Flags().StringVar(&f.notBefore, "not-before", "", "RFC 3339 time the key becomes valid")
+	cmd.Flags().DurationVar(&f.expiresIn, "expires-in", 0, "validity duration of the key (no expiry when zero)")
+	cmd.Flags().StringVar(&f.lookupSecret, "lookup-secret", "", "http.server.auth.api_keys.lookup_secret, required for keys without key ID")
+	_ = cmd.MarkFlagRequired("user")
+}
+
+func (f *entryFlags) entry(key string) (server.ApiKeyEntry, error) {
+	entry, err := server.HashApiKey(key, f.userID, []byte(f.lookupSecret))
+	if err != nil {
+		return entry, err
+	}
+	entry.Roles = f.roles
+	start := time.Now().UTC()
+	if f.notBefore != "" {
+		if start, err = time.Parse(time.RFC3339, f.notBefore); err != nil {
+			return entry, fmt.Errorf("invalid --not-before: %w", err)
+		}
+		entry.NotBefore = f.notBefore
+	}
+	if f.expiresIn > 0 {
+		entry.ExpiresAt = start.Add(f.expiresIn).Format(time.RFC3339)
+	}
+	return entry, nil
+}
+
+// writeEntry writes the entry as an item of the YAML `keys` list.
+func writeEntry(w io.Writer, entry server.ApiKeyEntry) {
+	_, _ = fmt.Fprintf(w, "- id: %s\n", strconv.Quote(entry.ID))
+	_, _ = fmt.Fprintf(w, "  hash: %s\n", strconv.Quote(entry.Hash))
+	_, _ = fmt.Fprintf(w, "  user_id: %s\n", strconv.Quote(entry.UserID))
+	if len(entry.Roles) > 0 {
+		roles := make([]string, len(entry.Roles))
+		for i, r := range entry.Roles {
+			roles[i] = strconv.Quote(r)
+		}
+		_, _ = fmt.Fprintf(w, "  roles: [%s]\n", strings.Join(roles, ", "))
+	}
+	if entry.NotBefore != "" {
+		_, _ = fmt.Fprintf(w, "  not_before: %s\n", strconv.Quote(entry.NotBefore))
+	}
+	if entry.ExpiresAt != "" {
+		_, _ = fmt.Fprintf(w, "  expires_at: %s\n", strconv.Quote(entry.ExpiresAt))
+	}
+}
+
+func generateCmd() *cobra.Command {
+	var flags entryFlags
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generates a new API key and its store entry",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			key, _, err := server.GenerateApiKey()
+			if err != nil {
+				return err
+			}
+			entry, err := flags.entry(key)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "API key (shown once): %s\n\n", key)
+			writeEntry(cmd.OutOrStdout(), entry)
+			return nil
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+func hashCmd() *cobra.Command {
+	var flags entryFlags
+	cmd := &cobra.Command{
+		Use:   "hash [key]",
+		Short: "Hashes an existing API key (read from stdin without argument) into a store entry",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var key string
+			if len(args) > 0 {
+				key = args[0]
+			} else {
+				line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && err != io.EOF {
+					return fmt.Errorf("reading key: %w", err)
+				}
+				key = strings.TrimSpace(line)
+			}
+			if key == "" {
+				return fmt.Errorf("no key given")
+			}
+			entry, err := flags.entry(key)
+			if err != nil {
+				return err
+			}
+			writeEntry(cmd.OutOrStdout(), entry)
+			return nil
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
+
+func main() {
+	root := &cobra.Command{
+		Use:          "apikey",
+		Short:        "Generates and hashes the API keys of the http.server.auth.api_keys store",
+		SilenceUsage: true,
+	}
+	root.AddCommand(generateCmd(), hashCmd())
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
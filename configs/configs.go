@@ -1,9 +1,14 @@
 package configs
 
 import (
+	"errors"
+	"fmt"
 	"maps"
+	"reflect"
 	"strings"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -23,6 +28,101 @@ func GetLogLevel() string {
 	return strings.ToLower(viper.GetString(LogLevelKey))
 }
 
+// GetLogExitTimeout returns how long Fatal and Panic logs wait for the
+// telemetry to be flushed before exiting or panicking.
+func GetLogExitTimeout() time.Duration {
+	return viper.GetDuration(LogExitTimeoutKey)
+}
+
+// GetLogLevels returns the per logger level overrides, indexed by logger name.
+func GetLogLevels() map[string]string {
+	levels := viper.GetStringMapString(LogLevelsKey)
+	for name, level := range levels {
+		levels[name] = strings.ToLower(level)
+	}
+	return levels
+}
+
+// SamplingBudget is the number of identical log entries allowed per
+// sampling interval: the first Initial ones, then every Thereafter-th one.
+type SamplingBudget struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+// LogSampling is the log sampling configuration.
+type LogSampling struct {
+	Enabled  bool
+	Interval time.Duration
+	SamplingBudget
+	// Levels holds the budgets overriding the default one, indexed by level name.
+	Levels map[string]SamplingBudget
+}
+
+// GetLogSampling returns the log sampling configuration, or an error if the
+// level budgets cannot be decoded.
+func GetLogSampling() (LogSampling, error) {
+	sampling := LogSampling{
+		Enabled:  viper.GetBool(LogSamplingEnabledKey),
+		Interval: viper.GetDuration(LogSamplingIntervalKey),
+		SamplingBudget: SamplingBudget{
+			Initial:    viper.GetInt(LogSamplingInitialKey),
+			Thereafter: viper.GetInt(LogSamplingThereafterKey),
+		},
+	}
+	levels := map[string]SamplingBudget{}
+	if err := viper.UnmarshalKey(LogSamplingLevelsKey, &levels); err != nil {
+		return sampling, fmt.Errorf("decoding %s: %w", LogSamplingLevelsKey, err)
+	}
+	if len(levels) > 0 {
+		sampling.Levels = make(map[string]SamplingBudget, len(levels))
+		for level, budget := range levels {
+			sampling.Levels[strings.ToLower(level)] = budget
+		}
+	}
+	return sampling, nil
+}
+
+// LogAsync is the asynchronous logging configuration.
+type LogAsync struct {
+	Enabled    bool
+	BufferSize int
+	// Overflow is the policy applied when the buffer is full (block,
+	// drop_newest, drop_oldest or drop_below_level).
+	Overflow string
+	// DropLevel is the level below which records are dropped by the
+	// drop_below_level policy.
+	DropLevel string
+}
+
+// GetLogAsync returns the asynchronous logging configuration.
+func GetLogAsync() LogAsync {
+	return LogAsync{
+		Enabled:    viper.GetBool(LogAsyncEnabledKey),
+		BufferSize: viper.GetInt(LogAsyncBufferSizeKey),
+		Overflow:   strings.ToLower(viper.GetString(LogAsyncOverflowKey)),
+		DropLevel:  strings.ToLower(viper.GetString(LogAsyncDropLevelKey)),
+	}
+}
+
+// LogRecent is the configuration of the in-memory buffer of recent logs.
+type LogRecent struct {
+	Enabled bool
+	// Size is the number of records kept.
+	Size int
+	// Level is the minimum level of the records kept.
+	Level string
+}
+
+// GetLogRecent returns the recent logs buffer configuration.
+func GetLogRecent() LogRecent {
+	return LogRecent{
+		Enabled: viper.GetBool(LogRecentEnabledKey),
+		Size:    viper.GetInt(LogRecentSizeKey),
+		Level:   strings.ToLower(viper.GetString(LogRecentLevelKey)),
+	}
+}
+
 // GetLogFormat returns the configured log format (JSON or text).
 func GetLogFormat() string {
 	return strings.ToLower(viper.GetString(LogFormatKey))
@@ -43,6 +143,68 @@ func GetLogKeysToRedact() []string {
 	return viper.GetStringSlice(LogKeysToRedactKey)
 }
 
+// RedactionPattern describes a value redaction rule. Preset selects one of
+// the built-in rules (`jwt`, `bearer`, `pan`, `email` or `ipv4`), otherwise
+// Regex is used. Mask overrides the default mask strategy for this rule.
+type RedactionPattern struct {
+	Name   string `mapstructure:"name"`
+	Preset string `mapstructure:"preset"`
+	Regex  string `mapstructure:"regex"`
+	Mask   string `mapstructure:"mask"`
+}
+
+// GetLogRedactionPatterns returns the configured value redaction rules, or
+// an error if they cannot be decoded. Entries may be plain preset names (e.g.
+// `[jwt, email]`) or rule objects.
+func GetLogRedactionPatterns() ([]RedactionPattern, error) {
+	val := viper.Get(LogRedactionPatternsKey)
+	if val == nil {
+		return nil, nil
+	}
+	if s, ok := val.(string); ok {
+		var patterns []RedactionPattern
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				patterns = append(patterns, RedactionPattern{Preset: p})
+			}
+		}
+		return patterns, nil
+	}
+	if items, ok := val.([]any); ok {
+		patterns := make([]RedactionPattern, 0, len(items))
+		for i, item := range items {
+			switch v := item.(type) {
+			case string:
+				patterns = append(patterns, RedactionPattern{Preset: v})
+			case map[string]any:
+				var p RedactionPattern
+				if err := mapstructure.Decode(v, &p); err != nil {
+					return nil, fmt.Errorf("decoding %s[%d]: %w", LogRedactionPatternsKey, i, err)
+				}
+				patterns = append(patterns, p)
+			default:
+				return nil, fmt.Errorf("decoding %s[%d]: expected a preset name or a rule object, got %T", LogRedactionPatternsKey, i, item)
+			}
+		}
+		return patterns, nil
+	}
+	var patterns []RedactionPattern
+	if err := viper.UnmarshalKey(LogRedactionPatternsKey, &patterns); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", LogRedactionPatternsKey, err)
+	}
+	return patterns, nil
+}
+
+// GetLogRedactionMask returns the default redaction mask strategy (full, partial or hash).
+func GetLogRedactionMask() string {
+	return strings.ToLower(viper.GetString(LogRedactionMaskKey))
+}
+
+// GetLogRedactionHashKey returns the secret used by the hash mask strategy.
+func GetLogRedactionHashKey() string {
+	return viper.GetString(LogRedactionHashKeyKey)
+}
+
 // GetTelemetryEnabled returns whether OpenTelemetry is enabled.
 func GetTelemetryEnabled() bool {
 	return viper.GetBool(TelemetryEnabledKey)
@@ -68,6 +230,341 @@ func GetLogsBackendEndpoint() string {
 	return viper.GetString(TelemetryLogsBackendEndpointKey)
 }
 
+// MetricView describes a metric view applied to the instruments matching
+// Instrument (wildcards `*` and `?` are accepted) and/or Meter.
+type MetricView struct {
+	// Instrument is the instrument name to match.
+	Instrument string `mapstructure:"instrument"`
+	// Meter is the instrumentation scope (meter) name to match.
+	Meter string `mapstructure:"meter"`
+	// Name renames the matched instrument.
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	Unit        string `mapstructure:"unit"`
+	// AllowedAttributes keeps only the listed attribute keys.
+	AllowedAttributes []string `mapstructure:"allowed_attributes"`
+	// DroppedAttributes removes the listed attribute keys.
+	DroppedAttributes []string `mapstructure:"dropped_attributes"`
+	// Aggregation is one of `default`, `drop`, `sum`, `last_value`,
+	// `explicit_bucket_histogram` or `exponential_histogram`.
+	Aggregation string `mapstructure:"aggregation"`
+	// Buckets are the explicit histogram bucket boundaries.
+	Buckets []float64 `mapstructure:"buckets"`
+	// MaxSize is the maximum number of buckets of an exponential histogram.
+	MaxSize int32 `mapstructure:"max_size"`
+	// MaxScale is the maximum scale of an exponential histogram.
+	MaxScale int32 `mapstructure:"max_scale"`
+	// NoMinMax disables min and max recording for histograms.
+	NoMinMax bool `mapstructure:"no_min_max"`
+}
+
+// GetTelemetryMetricViews returns the configured metric views, or an error
+// if the configuration cannot be decoded.
+func GetTelemetryMetricViews() ([]MetricView, error) {
+	var views []MetricView
+	if err := viper.UnmarshalKey(TelemetryMetricsViewsKey, &views); err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// GetTelemetryMetricsCardinalityLimit returns the configured metric cardinality limit.
+// Zero means the SDK default is used.
+func GetTelemetryMetricsCardinalityLimit() int {
+	return viper.GetInt(TelemetryMetricsCardinalityLimitKey)
+}
+
+// HTTPServer is the HTTP server configuration.
+type HTTPServer struct {
+	Address           string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout is the grace period given to the in-flight requests
+	// when the server shuts down.
+	ShutdownTimeout time.Duration
+	TLS             HTTPServerTLS
+}
+
+// HTTPServerTLS is the HTTP server TLS configuration, enabled when the
+// certificate file is defined.
+type HTTPServerTLS struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the CA bundle verifying the client certificates.
+	ClientCAFile string
+	// ClientAuth is the client certificate policy (none, request, require,
+	// verify_if_given or require_and_verify), require_and_verify by default
+	// when ClientCAFile is defined.
+	ClientAuth string
+	// MinVersion is the minimum TLS version (1.0, 1.1, 1.2 or 1.3).
+	MinVersion string
+	// CipherSuites are the names of the TLS 1.0-1.2 cipher suites allowed
+	// (like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), the Go defaults when empty.
+	CipherSuites []string
+	// ReloadInterval is how often the certificate files are checked for changes.
+	ReloadInterval time.Duration
+}
+
+// GetHTTPServer returns the HTTP server configuration.
+func GetHTTPServer() HTTPServer {
+	return HTTPServer{
+		Address:           viper.GetString(HTTPServerAddressKey),
+		ReadTimeout:       viper.GetDuration(HTTPServerReadTimeoutKey),
+		ReadHeaderTimeout: viper.GetDuration(HTTPServerReadHeaderTimeoutKey),
+		WriteTimeout:      viper.GetDuration(HTTPServerWriteTimeoutKey),
+		IdleTimeout:       viper.GetDuration(HTTPServerIdleTimeoutKey),
+		MaxHeaderBytes:    viper.GetInt(HTTPServerMaxHeaderBytesKey),
+		ShutdownTimeout:   viper.GetDuration(HTTPServerShutdownTimeoutKey),
+		TLS: HTTPServerTLS{
+			CertFile:       viper.GetString(HTTPServerTLSCertFileKey),
+			KeyFile:        viper.GetString(HTTPServerTLSKeyFileKey),
+			ClientCAFile:   viper.GetString(HTTPServerTLSClientCAFileKey),
+			ClientAuth:     strings.ToLower(viper.GetString(HTTPServerTLSClientAuthKey)),
+			MinVersion:     viper.GetString(HTTPServerTLSMinVersionKey),
+			CipherSuites:   viper.GetStringSlice(HTTPServerTLSCipherSuitesKey),
+			ReloadInterval: viper.GetDuration(HTTPServerTLSReloadIntervalKey),
+		},
+	}
+}
+
+// ClientCertAuth is the client certificate authentication configuration.
+type ClientCertAuth struct {
+	// CAFile is the CA bundle verifying the client certificates, the chains
+	// verified by the TLS handshake are used when empty.
+	CAFile string
+	// AllowedCommonNames are the subject common names allowed.
+	AllowedCommonNames []string
+	// AllowedURIs are the SAN URIs allowed (like SPIFFE IDs), a trailing `*`
+	// matching any suffix.
+	AllowedURIs []string
+}
+
+// GetClientCertAuth returns the client certificate authentication configuration.
+func GetClientCertAuth() ClientCertAuth {
+	return ClientCertAuth{
+		CAFile:             viper.GetString(HTTPServerAuthClientCertCAFileKey),
+		AllowedCommonNames: viper.GetStringSlice(HTTPServerAuthClientCertAllowedCommonNamesKey),
+		AllowedURIs:        viper.GetStringSlice(HTTPServerAuthClientCertAllowedURIsKey),
+	}
+}
+
+// JWTAuth is the JWT bearer authentication configuration.
+type JWTAuth struct {
+	// Issuer is the expected `iss` claim, not checked when empty.
+	Issuer string
+	// Audience are the accepted `aud` claims, not checked when empty.
+	Audience []string
+	// Algorithms are the accepted signing algorithms (RS256, ES256, EdDSA or
+	// HS256).
+	Algorithms []string
+	// ClockSkew is the tolerance applied to the `exp` and `nbf` claims.
+	ClockSkew time.Duration
+	// JWKSURL is the URL of the JWKS serving the verification keys.
+	JWKSURL string
+	// JWKSFile is a static JWKS file of verification keys.
+	JWKSFile string
+	// JWKSRefreshInterval is how often the JWKS URL keys are refreshed.
+	JWKSRefreshInterval time.Duration
+	// Secret is the HS256 shared secret.
+	Secret string
+	// UserIDClaim is the claim identifying the user.
+	UserIDClaim string
+}
+
+// GetJWTAuth returns the JWT bearer authentication configuration.
+func GetJWTAuth() JWTAuth {
+	return JWTAuth{
+		Issuer:              viper.GetString(HTTPServerAuthJWTIssuerKey),
+		Audience:            viper.GetStringSlice(HTTPServerAuthJWTAudienceKey),
+		Algorithms:          viper.GetStringSlice(HTTPServerAuthJWTAlgorithmsKey),
+		ClockSkew:           viper.GetDuration(HTTPServerAuthJWTClockSkewKey),
+		JWKSURL:             viper.GetString(HTTPServerAuthJWTJWKSURLKey),
+		JWKSFile:            viper.GetString(HTTPServerAuthJWTJWKSFileKey),
+		JWKSRefreshInterval: viper.GetDuration(HTTPServerAuthJWTJWKSRefreshIntervalKey),
+		Secret:              viper.GetString(HTTPServerAuthJWTSecretKey),
+		UserIDClaim:         viper.GetString(HTTPServerAuthJWTUserIDClaimKey),
+	}
+}
+
+// BasicAuth is the HTTP Basic authentication configuration.
+type BasicAuth struct {
+	// HtpasswdFile is the htpasswd file of the users (bcrypt or SHA entries).
+	HtpasswdFile string
+	// Realm is the realm sent in the `WWW-Authenticate` challenge.
+	Realm string
+	// ReloadInterval is how often the htpasswd file is checked for changes.
+	ReloadInterval time.Duration
+}
+
+// GetBasicAuth returns the HTTP Basic authentication configuration.
+func GetBasicAuth() BasicAuth {
+	return BasicAuth{
+		HtpasswdFile:   viper.GetString(HTTPServerAuthBasicHtpasswdFileKey),
+		Realm:          viper.GetString(HTTPServerAuthBasicRealmKey),
+		ReloadInterval: viper.GetDuration(HTTPServerAuthBasicReloadIntervalKey),
+	}
+}
+
+// ApiKeyEntry is an API key of the store, identified by its key ID (the
+// `ak_<id>_` prefix of the key, or its HMAC lookup token for keys without it).
+type ApiKeyEntry struct {
+	ID string `mapstructure:"id" json:"id"`
+	// Hash is the bcrypt hash of the key.
+	Hash   string   `mapstructure:"hash" json:"hash"`
+	UserID string   `mapstructure:"user_id" json:"user_id"`
+	Roles  []string `mapstructure:"roles" json:"roles,omitempty"`
+	// NotBefore and ExpiresAt are the RFC 3339 validity period of the key,
+	// unbounded when empty.
+	NotBefore string `mapstructure:"not_before" json:"not_before,omitempty"`
+	ExpiresAt string `mapstructure:"expires_at" json:"expires_at,omitempty"`
+}
+
+// ApiKeys is the API key store configuration.
+type ApiKeys struct {
+	// File is a YAML or JSON file of keys (a `keys` list), reloaded on change.
+	File string
+	// Keys are the keys defined in the configuration.
+	Keys []ApiKeyEntry
+	// LookupSecret is the HMAC secret of the lookup tokens of the keys without
+	// key ID.
+	LookupSecret string
+	// ReloadInterval is how often the file is checked for changes.
+	ReloadInterval time.Duration
+}
+
+// GetApiKeys returns the API key store configuration, or an error if the
+// keys cannot be decoded.
+func GetApiKeys() (ApiKeys, error) {
+	cfg := ApiKeys{
+		File:           viper.GetString(HTTPServerAuthApiKeysFileKey),
+		LookupSecret:   viper.GetString(HTTPServerAuthApiKeysLookupSecretKey),
+		ReloadInterval: viper.GetDuration(HTTPServerAuthApiKeysReloadIntervalKey),
+	}
+	var err error
+	cfg.Keys, err = DecodeApiKeys(viper.GetViper(), HTTPServerAuthApiKeysKeysKey)
+	return cfg, err
+}
+
+// DecodeApiKeys decodes the API keys list at key of v. The unquoted YAML
+// timestamps of the validity periods are decoded as RFC 3339 strings.
+func DecodeApiKeys(v *viper.Viper, key string) ([]ApiKeyEntry, error) {
+	var keys []ApiKeyEntry
+	err := v.UnmarshalKey(key, &keys, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		timeToStringHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+	return keys, err
+}
+
+func timeToStringHook(from, to reflect.Type, data any) (any, error) {
+	if t, ok := data.(time.Time); ok && to.Kind() == reflect.String {
+		return t.Format(time.RFC3339), nil
+	}
+	return data, nil
+}
+
+// AuthLimiter is the authentication failure limiter configuration.
+type AuthLimiter struct {
+	// Enabled enables the limiter of the authentication middlewares.
+	Enabled bool
+	// MaxFailures is the number of failures within Window blocking a client
+	// IP or credential.
+	MaxFailures int
+	Window      time.Duration
+	// BlockDuration is how long a client IP or credential stays blocked.
+	BlockDuration time.Duration
+	// MaxEntries is the number of client IPs and credentials tracked, the
+	// least recently failing ones being forgotten beyond.
+	MaxEntries int
+	// TrustedProxies are the IPs or CIDRs of the proxies whose forwarded
+	// client IP is used.
+	TrustedProxies []string
+}
+
+// GetAuthLimiter returns the authentication failure limiter configuration.
+func GetAuthLimiter() AuthLimiter {
+	return AuthLimiter{
+		Enabled:        viper.GetBool(HTTPServerAuthLimiterEnabledKey),
+		MaxFailures:    viper.GetInt(HTTPServerAuthLimiterMaxFailuresKey),
+		Window:         viper.GetDuration(HTTPServerAuthLimiterWindowKey),
+		BlockDuration:  viper.GetDuration(HTTPServerAuthLimiterBlockDurationKey),
+		MaxEntries:     viper.GetInt(HTTPServerAuthLimiterMaxEntriesKey),
+		TrustedProxies: viper.GetStringSlice(HTTPServerAuthLimiterTrustedProxiesKey),
+	}
+}
+
+// RateLimit is a token bucket limit of Requests per Period, allowing bursts of
+// Burst requests (Requests when zero).
+type RateLimit struct {
+	Requests int           `mapstructure:"requests"`
+	Period   time.Duration `mapstructure:"period"`
+	Burst    int           `mapstructure:"burst"`
+}
+
+// RouteRateLimit is the limit of an http.ServeMux pattern.
+type RouteRateLimit struct {
+	Pattern   string `mapstructure:"pattern"`
+	RateLimit `mapstructure:",squash"`
+}
+
+// HTTPServerRateLimit is the rate limiting middleware configuration.
+type HTTPServerRateLimit struct {
+	// Default is the limit of the requests without a route or tier limit.
+	Default RateLimit
+	// Tiers are the limits by tier of the authenticated users.
+	Tiers map[string]RateLimit
+	// TierKey is the UserData entry holding the tier of the user.
+	TierKey string
+	Routes  []RouteRateLimit
+	// TrustedProxies are the IPs or CIDRs of the proxies whose forwarded
+	// client IP is used.
+	TrustedProxies []string
+}
+
+// GetRateLimit returns the rate limiting middleware configuration, or an
+// error if the tiers or routes cannot be decoded.
+func GetRateLimit() (HTTPServerRateLimit, error) {
+	cfg := HTTPServerRateLimit{
+		Default: RateLimit{
+			Requests: viper.GetInt(HTTPServerRateLimitRequestsKey),
+			Period:   viper.GetDuration(HTTPServerRateLimitPeriodKey),
+			Burst:    viper.GetInt(HTTPServerRateLimitBurstKey),
+		},
+		TierKey:        viper.GetString(HTTPServerRateLimitTierKeyKey),
+		TrustedProxies: viper.GetStringSlice(HTTPServerRateLimitTrustedProxiesKey),
+	}
+	var errs []error
+	if err := viper.UnmarshalKey(HTTPServerRateLimitTiersKey, &cfg.Tiers); err != nil {
+		errs = append(errs, fmt.Errorf("decoding %s: %w", HTTPServerRateLimitTiersKey, err))
+	}
+	if err := viper.UnmarshalKey(HTTPServerRateLimitRoutesKey, &cfg.Routes); err != nil {
+		errs = append(errs, fmt.Errorf("decoding %s: %w", HTTPServerRateLimitRoutesKey, err))
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// AuthzRoute is the permission required by an http.ServeMux pattern (like
+// `DELETE /api/orders/{id}`): one of the roles and all the scopes.
+type AuthzRoute struct {
+	Pattern string   `mapstructure:"pattern"`
+	Roles   []string `mapstructure:"roles"`
+	Scopes  []string `mapstructure:"scopes"`
+}
+
+// GetAuthzRoutes returns the route-to-permission table of the authorization,
+// or an error if the configuration cannot be decoded.
+func GetAuthzRoutes() ([]AuthzRoute, error) {
+	var routes []AuthzRoute
+	if err := viper.UnmarshalKey(HTTPServerAuthzRoutesKey, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
 // ConfigOptionFunc is a function type for configuring default options.
 type ConfigOptionFunc func(defaultOptions map[string]any)
 
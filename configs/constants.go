@@ -7,10 +7,133 @@ const (
 	LogOutputFileKey     = "log.output_to_file"
 	LogOutputToStdoutKey = "log.output_to_stdout"
 	LogKeysToRedactKey   = "log.redacted_keys"
+	LogLevelsKey         = "log.levels"
+	LogExitTimeoutKey    = "log.exit_timeout"
+
+	// Configuration keys for log sampling
+	LogSamplingEnabledKey    = "log.sampling.enabled"
+	LogSamplingIntervalKey   = "log.sampling.interval"
+	LogSamplingInitialKey    = "log.sampling.initial"
+	LogSamplingThereafterKey = "log.sampling.thereafter"
+	LogSamplingLevelsKey     = "log.sampling.levels"
+
+	// Configuration keys for asynchronous logging
+	LogAsyncEnabledKey    = "log.async.enabled"
+	LogAsyncBufferSizeKey = "log.async.buffer_size"
+	LogAsyncOverflowKey   = "log.async.overflow"
+	LogAsyncDropLevelKey  = "log.async.drop_level"
+
+	// Configuration keys for the in-memory buffer of recent logs
+	LogRecentEnabledKey = "log.recent.enabled"
+	LogRecentSizeKey    = "log.recent.size"
+	LogRecentLevelKey   = "log.recent.level"
+
+	// Asynchronous logging overflow policies
+	LogAsyncOverflowBlock          = "block"
+	LogAsyncOverflowDropNewest     = "drop_newest"
+	LogAsyncOverflowDropOldest     = "drop_oldest"
+	LogAsyncOverflowDropBelowLevel = "drop_below_level"
+
+	// Configuration keys for the HTTP server
+	HTTPServerAddressKey           = "http.server.address"
+	HTTPServerReadTimeoutKey       = "http.server.read_timeout"
+	HTTPServerReadHeaderTimeoutKey = "http.server.read_header_timeout"
+	HTTPServerWriteTimeoutKey      = "http.server.write_timeout"
+	HTTPServerIdleTimeoutKey       = "http.server.idle_timeout"
+	HTTPServerMaxHeaderBytesKey    = "http.server.max_header_bytes"
+	HTTPServerShutdownTimeoutKey   = "http.server.shutdown_timeout"
+
+	// Configuration keys for the HTTP server TLS
+	HTTPServerTLSCertFileKey       = "http.server.tls.cert_file"
+	HTTPServerTLSKeyFileKey        = "http.server.tls.key_file"
+	HTTPServerTLSClientCAFileKey   = "http.server.tls.client_ca_file"
+	HTTPServerTLSClientAuthKey     = "http.server.tls.client_auth"
+	HTTPServerTLSMinVersionKey     = "http.server.tls.min_version"
+	HTTPServerTLSCipherSuitesKey   = "http.server.tls.cipher_suites"
+	HTTPServerTLSReloadIntervalKey = "http.server.tls.reload_interval"
+
+	// Configuration keys for the client certificate authentication
+	HTTPServerAuthClientCertCAFileKey             = "http.server.auth.client_cert.ca_file"
+	HTTPServerAuthClientCertAllowedCommonNamesKey = "http.server.auth.client_cert.allowed_common_names"
+	HTTPServerAuthClientCertAllowedURIsKey        = "http.server.auth.client_cert.allowed_uris"
+
+	// Configuration keys for the JWT bearer authentication
+	HTTPServerAuthJWTIssuerKey              = "http.server.auth.jwt.issuer"
+	HTTPServerAuthJWTAudienceKey            = "http.server.auth.jwt.audience"
+	HTTPServerAuthJWTAlgorithmsKey          = "http.server.auth.jwt.algorithms"
+	HTTPServerAuthJWTClockSkewKey           = "http.server.auth.jwt.clock_skew"
+	HTTPServerAuthJWTJWKSURLKey             = "http.server.auth.jwt.jwks_url"
+	HTTPServerAuthJWTJWKSFileKey            = "http.server.auth.jwt.jwks_file"
+	HTTPServerAuthJWTJWKSRefreshIntervalKey = "http.server.auth.jwt.jwks_refresh_interval"
+	HTTPServerAuthJWTSecretKey              = "http.server.auth.jwt.secret"
+	HTTPServerAuthJWTUserIDClaimKey         = "http.server.auth.jwt.user_id_claim"
+
+	// Configuration keys for the HTTP Basic authentication
+	HTTPServerAuthBasicHtpasswdFileKey   = "http.server.auth.basic.htpasswd_file"
+	HTTPServerAuthBasicRealmKey          = "http.server.auth.basic.realm"
+	HTTPServerAuthBasicReloadIntervalKey = "http.server.auth.basic.reload_interval"
+
+	// Configuration keys for the API key store
+	HTTPServerAuthApiKeysFileKey           = "http.server.auth.api_keys.file"
+	HTTPServerAuthApiKeysKeysKey           = "http.server.auth.api_keys.keys"
+	HTTPServerAuthApiKeysLookupSecretKey   = "http.server.auth.api_keys.lookup_secret"
+	HTTPServerAuthApiKeysReloadIntervalKey = "http.server.auth.api_keys.reload_interval"
+
+	// Configuration keys for the authentication failure limiter
+	HTTPServerAuthLimiterEnabledKey        = "http.server.auth.limiter.enabled"
+	HTTPServerAuthLimiterMaxFailuresKey    = "http.server.auth.limiter.max_failures"
+	HTTPServerAuthLimiterWindowKey         = "http.server.auth.limiter.window"
+	HTTPServerAuthLimiterBlockDurationKey  = "http.server.auth.limiter.block_duration"
+	HTTPServerAuthLimiterMaxEntriesKey     = "http.server.auth.limiter.max_entries"
+	HTTPServerAuthLimiterTrustedProxiesKey = "http.server.auth.limiter.trusted_proxies"
+
+	// Configuration keys for the rate limiting middleware
+	HTTPServerRateLimitRequestsKey       = "http.server.rate_limit.requests"
+	HTTPServerRateLimitPeriodKey         = "http.server.rate_limit.period"
+	HTTPServerRateLimitBurstKey          = "http.server.rate_limit.burst"
+	HTTPServerRateLimitTiersKey          = "http.server.rate_limit.tiers"
+	HTTPServerRateLimitTierKeyKey        = "http.server.rate_limit.tier_key"
+	HTTPServerRateLimitRoutesKey         = "http.server.rate_limit.routes"
+	HTTPServerRateLimitTrustedProxiesKey = "http.server.rate_limit.trusted_proxies"
+
+	// HTTPServerAuthzRoutesKey is the route-to-permission table of the authorization
+	HTTPServerAuthzRoutesKey = "http.server.authz.routes"
+
+	// HTTP server TLS client authentication policies
+	TLSClientAuthNone             = "none"
+	TLSClientAuthRequest          = "request"
+	TLSClientAuthRequire          = "require"
+	TLSClientAuthVerifyIfGiven    = "verify_if_given"
+	TLSClientAuthRequireAndVerify = "require_and_verify"
+
+	// Configuration keys for value redaction
+	LogRedactionPatternsKey = "log.redaction.patterns"
+	LogRedactionMaskKey     = "log.redaction.mask"
+	LogRedactionHashKeyKey  = "log.redaction.hash_key"
+
+	// Redaction mask strategies
+	RedactionMaskFull    = "full"
+	RedactionMaskPartial = "partial"
+	RedactionMaskHash    = "hash"
+
+	// Redaction pattern presets
+	RedactionPresetJWT    = "jwt"
+	RedactionPresetBearer = "bearer"
+	RedactionPresetPAN    = "pan"
+	RedactionPresetEmail  = "email"
+	RedactionPresetIPv4   = "ipv4"
 
 	// Log format constants
 	LogFormatJSON = "json"
 	LogFormatText = "text"
+	// LogFormatConsole is a colored human friendly format for local development
+	LogFormatConsole = "console"
+	// LogFormatECS is a JSON format following the Elastic Common Schema
+	LogFormatECS = "ecs"
+	// LogFormatGCP is a JSON format following the Google Cloud Logging structured fields
+	LogFormatGCP = "gcp"
+	// LogFormatLogfmt is the logfmt format (`key=value` pairs)
+	LogFormatLogfmt = "logfmt"
 
 	// Log level constants
 	LogLevelINFO  = "info"
@@ -24,20 +147,60 @@ const (
 	TelemetryMetricsBackendEndpointKey = "telemetry.metrics.endpoint"
 	TelemetryLogsBackendEndpointKey    = "telemetry.logs.endpoint"
 	TelemetryDebugKey                  = "telemetry.debug"
+
+	// Configuration keys for metric views
+	TelemetryMetricsViewsKey            = "telemetry.metrics.views"
+	TelemetryMetricsCardinalityLimitKey = "telemetry.metrics.cardinality_limit"
+
+	// Metric view aggregation constants
+	MetricAggregationDefault              = "default"
+	MetricAggregationDrop                 = "drop"
+	MetricAggregationSum                  = "sum"
+	MetricAggregationLastValue            = "last_value"
+	MetricAggregationExplicitHistogram    = "explicit_bucket_histogram"
+	MetricAggregationExponentialHistogram = "exponential_histogram"
 )
 
 var (
 	// DefaultConfigValuesMap contains the default values for all configuration keys.
 	DefaultConfigValuesMap = map[string]any{
-		LogFormatKey:                       LogFormatJSON,
-		LogLevelKey:                        LogLevelINFO,
-		LogOutputFileKey:                   "",
-		LogOutputToStdoutKey:               false,
-		LogKeysToRedactKey:                 []string{},
-		TelemetryEnabledKey:                false,
-		TelemetryTracesBackendEndpointKey:  "",
-		TelemetryMetricsBackendEndpointKey: "",
-		TelemetryLogsBackendEndpointKey:    "",
+		LogFormatKey:                            LogFormatJSON,
+		LogLevelKey:                             LogLevelINFO,
+		LogOutputFileKey:                        "",
+		LogOutputToStdoutKey:                    false,
+		LogKeysToRedactKey:                      []string{},
+		LogRedactionMaskKey:                     RedactionMaskFull,
+		LogExitTimeoutKey:                       "5s",
+		LogSamplingEnabledKey:                   false,
+		LogAsyncEnabledKey:                      false,
+		LogAsyncBufferSizeKey:                   1024,
+		LogAsyncOverflowKey:                     LogAsyncOverflowBlock,
+		LogAsyncDropLevelKey:                    LogLevelWARN,
+		LogSamplingIntervalKey:                  "1s",
+		LogRecentEnabledKey:                     false,
+		LogRecentSizeKey:                        1000,
+		LogRecentLevelKey:                       LogLevelDEBUG,
+		LogSamplingInitialKey:                   100,
+		LogSamplingThereafterKey:                100,
+		HTTPServerAddressKey:                    ":8080",
+		HTTPServerReadTimeoutKey:                "30s",
+		HTTPServerReadHeaderTimeoutKey:          "10s",
+		HTTPServerWriteTimeoutKey:               "30s",
+		HTTPServerIdleTimeoutKey:                "120s",
+		HTTPServerMaxHeaderBytesKey:             1 << 20,
+		HTTPServerShutdownTimeoutKey:            "30s",
+		HTTPServerTLSMinVersionKey:              "1.2",
+		HTTPServerTLSReloadIntervalKey:          "10s",
+		HTTPServerAuthJWTClockSkewKey:           "1m",
+		HTTPServerAuthJWTJWKSRefreshIntervalKey: "15m",
+		HTTPServerAuthJWTUserIDClaimKey:         "sub",
+		HTTPServerRateLimitRequestsKey:          100,
+		HTTPServerRateLimitPeriodKey:            "1m",
+		HTTPServerRateLimitTierKeyKey:           "tier",
+		TelemetryEnabledKey:                     false,
+		TelemetryTracesBackendEndpointKey:       "",
+		TelemetryMetricsBackendEndpointKey:      "",
+		TelemetryLogsBackendEndpointKey:         "",
 	}
 
 	// DefaultConfigValuesLogFileMap provides defaults with file logging enabled.
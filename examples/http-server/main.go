@@ -36,9 +36,17 @@ func main() {
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	// Throttles the clients failing to authenticate when
+	// http.server.auth.limiter.enabled is set
+	limiter, err := server.AuthFailureLimiterFromConfig()
+	if err != nil {
+		panic(err)
+	}
+
 	// Protected endpoint with single API key auth
 	auth := server.AuthenticationMiddleware(
 		server.SingleUserApiKeyAuthenticationFunc("my-secret-key", "", myUser{id: "1", name: "test-user"}),
+		server.WithAuthFailureLimiter(limiter),
 	)
 	mux.Handle("GET /api/protected", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := server.AuthenticatedUserFromContext(r.Context())
@@ -46,8 +54,9 @@ func main() {
 		_, _ = w.Write([]byte(`{"secret":"data"}`))
 	})))
 
-	slog.Info("server listening on :8080")
-	if err := http.ListenAndServe(":8080", server.TelemetryMiddleware(mux)); err != nil {
+	// Listens to http.server.address (:8080 by default) until SIGTERM or
+	// SIGINT, then drains the in-flight requests and flushes the telemetry
+	if err := server.New(mux).Run(context.Background()); err != nil {
 		slog.Error("server failed", "error", err)
 	}
 }
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// ApiKeyPrefix starts the keys generated by GenerateApiKey
+	// (`ak_<key ID>_<secret>`).
+	ApiKeyPrefix = "ak"
+	// DefaultApiKeysReloadInterval is how often the API keys file is checked
+	// for changes when not configured.
+	DefaultApiKeysReloadInterval = 10 * time.Second
+
+	apiKeyIDSize     = 8
+	apiKeySecretSize = 32
+)
+
+// ErrInvalidApiKeyStore is returned when the API key store configuration is invalid.
+var ErrInvalidApiKeyStore = errors.New("invalid API key store")
+
+// ApiKeyEntry is an API key of the store.
+type ApiKeyEntry = configs.ApiKeyEntry
+
+// ApiKeyStoreConfig is the API key store configuration (see the
+// `http.server.auth.api_keys.*` config keys).
+type ApiKeyStoreConfig = configs.ApiKeys
+
+// ApiKeyUser is the user of a key of the ApiKeyStore.
+type ApiKeyUser struct {
+	ID    string
+	KeyID string
+	Roles []string
+}
+
+func (u ApiKeyUser) UserID() string {
+	return u.ID
+}
+
+func (u ApiKeyUser) UserData() map[string]any {
+	return map[string]any{"key_id": u.KeyID, "roles": u.Roles}
+}
+
+// UserRoles returns the roles of the key, checked by RequireRoles.
+func (u ApiKeyUser) UserRoles() []string {
+	return u.Roles
+}
+
+type apiKeyStoreEntry struct {
+	hash      []byte
+	user      ApiKeyUser
+	notBefore time.Time
+	expiresAt time.Time
+}
+
+// ApiKeyStore stores the bcrypt hashes of the API keys indexed by key ID, so
+// a key is checked against a single hash. The key ID is the `ak_<id>_`
+// prefix of the keys generated by GenerateApiKey, or the HMAC lookup token of
+// other keys. The keys of the file are reloaded atomically when it changes.
+type ApiKeyStore struct {
+	cfg          ApiKeyStoreConfig
+	lookupSecret []byte
+	interval     time.Duration
+
+	entries atomic.Pointer[map[string]apiKeyStoreEntry]
+
+	// mu guards the file checks
+	mu        sync.Mutex
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// ApiKeyStoreFromConfig creates the store defined by the
+// `http.server.auth.api_keys.*` config keys.
+func ApiKeyStoreFromConfig() (*ApiKeyStore, error) {
+	cfg, err := configs.GetApiKeys()
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding %s: %w", ErrInvalidApiKeyStore, configs.HTTPServerAuthApiKeysKeysKey, err)
+	}
+	return NewApiKeyStore(cfg)
+}
+
+// NewApiKeyStore creates a store of the keys of cfg and of its file.
+func NewApiKeyStore(cfg ApiKeyStoreConfig) (*ApiKeyStore, error) {
+	s := &ApiKeyStore{
+		cfg:          cfg,
+		lookupSecret: []byte(cfg.LookupSecret),
+		interval:     orDefault(cfg.ReloadInterval, DefaultApiKeysReloadInterval),
+	}
+	var modTime time.Time
+	if cfg.File != "" {
+		info, err := os.Stat(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("reading API keys file: %w", err)
+		}
+		modTime = info.ModTime()
+	}
+	if err := s.load(modTime); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load indexes the keys of the configuration and of the file.
+func (s *ApiKeyStore) load(modTime time.Time) error {
+	keys := s.cfg.Keys
+	if s.cfg.File != "" {
+		v := viper.New()
+		v.SetConfigFile(s.cfg.File)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("reading API keys file: %w", err)
+		}
+		fileKeys, err := configs.DecodeApiKeys(v, "keys")
+		if err != nil {
+			return fmt.Errorf("%w: decoding %s: %w", ErrInvalidApiKeyStore, s.cfg.File, err)
+		}
+		keys = append(keys[:len(keys):len(keys)], fileKeys...)
+	}
+
+	entries := make(map[string]apiKeyStoreEntry, len(keys))
+	for _, k := range keys {
+		entry, err := newApiKeyStoreEntry(k)
+		if err != nil {
+			return err
+		}
+		if _, ok := entries[k.ID]; ok {
+			return fmt.Errorf("%w: duplicate key ID %q", ErrInvalidApiKeyStore, k.ID)
+		}
+		entries[k.ID] = entry
+	}
+	s.entries.Store(&entries)
+	s.modTime = modTime
+	s.checkedAt = time.Now()
+	return nil
+}
+
+func newApiKeyStoreEntry(k ApiKeyEntry) (apiKeyStoreEntry, error) {
+	if k.ID == "" || k.UserID == "" {
+		return apiKeyStoreEntry{}, fmt.Errorf("%w: the key ID and user ID are required", ErrInvalidApiKeyStore)
+	}
+	if !isBcryptHash(k.Hash) {
+		return apiKeyStoreEntry{}, fmt.Errorf("%w: key %q hash is not a bcrypt hash", ErrInvalidApiKeyStore, k.ID)
+	}
+	entry := apiKeyStoreEntry{
+		hash: []byte(k.Hash),
+		user: ApiKeyUser{ID: k.UserID, KeyID: k.ID, Roles: k.Roles},
+	}
+	var err error
+	if k.NotBefore != "" {
+		if entry.notBefore, err = time.Parse(time.RFC3339, k.NotBefore); err != nil {
+			return apiKeyStoreEntry{}, fmt.Errorf("%w: key %q not_before: %w", ErrInvalidApiKeyStore, k.ID, err)
+		}
+	}
+	if k.ExpiresAt != "" {
+		if entry.expiresAt, err = time.Parse(time.RFC3339, k.ExpiresAt); err != nil {
+			return apiKeyStoreEntry{}, fmt.Errorf("%w: key %q expires_at: %w", ErrInvalidApiKeyStore, k.ID, err)
+		}
+	}
+	return entry, nil
+}
+
+// reloadIfChanged loads the file again when it changed, checking it at most
+// once per interval. A concurrent check is not waited for.
+func (s *ApiKeyStore) reloadIfChanged() {
+	if s.cfg.File == "" || !s.mu.TryLock() {
+		return
+	}
+	defer s.mu.Unlock()
+	if time.Since(s.checkedAt) < s.interval {
+		return
+	}
+	s.checkedAt = time.Now()
+	info, err := os.Stat(s.cfg.File)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	if err := s.load(info.ModTime()); err != nil {
+		slog.Error("failed to reload API keys file, keeping the current keys", "file", s.cfg.File, "error", err)
+		return
+	}
+	slog.Info("API keys file reloaded", "file", s.cfg.File, "keys", len(*s.entries.Load()))
+}
+
+// Authenticate returns the user of the key, valid at now.
+func (s *ApiKeyStore) Authenticate(key string, now time.Time) (User, error) {
+	s.reloadIfChanged()
+	entry, ok := (*s.entries.Load())[ApiKeyID(key, s.lookupSecret)]
+	if !ok || bcrypt.CompareHashAndPassword(entry.hash, []byte(key)) != nil {
+		return nil, ErrNotAuthorized
+	}
+	if !entry.notBefore.IsZero() && now.Before(entry.notBefore) {
+		return nil, fmt.Errorf("%w: key %s not valid yet", ErrNotAuthorized, entry.user.KeyID)
+	}
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		return nil, fmt.Errorf("%w: key %s expired", ErrNotAuthorized, entry.user.KeyID)
+	}
+	return entry.user, nil
+}
+
+// ApiKeyStoreAuthenticationFunc authenticates the user from the API key of the
+// header (DefaultXApiKeyHeaderName when empty) against the store.
+func ApiKeyStoreAuthenticationFunc(store *ApiKeyStore, headerName string) UserAuthenticationFunc {
+	headerName = defineHeaderName(headerName)
+	return func(r *http.Request) (User, error) {
+		key := r.Header.Get(headerName)
+		if key == "" {
+			return nil, ErrNoCredentials
+		}
+		setAuthAttempt(r, AuthMethodAPIKey, headerName, credentialFingerprint(key))
+		user, err := store.Authenticate(key, time.Now())
+		if err != nil {
+			slog.DebugContext(r.Context(), "API key authentication failed", "error", err)
+			return nil, ErrNotAuthorized
+		}
+		return user, nil
+	}
+}
+
+// ApiKeyID returns the key ID of the key: the ID of its `ak_<id>_` prefix,
+// or its HMAC lookup token with the lookup secret. Keys without key ID have
+// no ID (empty) without a lookup secret, so the store rejects them.
+func ApiKeyID(key string, lookupSecret []byte) string {
+	if rest, ok := strings.CutPrefix(key, ApiKeyPrefix+"_"); ok {
+		if id, _, ok := strings.Cut(rest, "_"); ok && id != "" {
+			return id
+		}
+	}
+	if len(lookupSecret) == 0 {
+		return ""
+	}
+	return lookupToken(lookupSecret, key)
+}
+
+// GenerateApiKey returns a new random key (`ak_<key ID>_<secret>`) and its key ID.
+func GenerateApiKey() (key, id string, err error) {
+	idBytes := make([]byte, apiKeyIDSize)
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("generating API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("generating API key: %w", err)
+	}
+	id = hex.EncodeToString(idBytes)
+	return ApiKeyPrefix + "_" + id + "_" + base64.RawURLEncoding.EncodeToString(secret), id, nil
+}
+
+// HashApiKey returns the store entry of the key for the user, identified by
+// ApiKeyID with the lookup secret, required for the keys without key ID.
+func HashApiKey(key, userID string, lookupSecret []byte) (ApiKeyEntry, error) {
+	id := ApiKeyID(key, lookupSecret)
+	if id == "" {
+		return ApiKeyEntry{}, fmt.Errorf("%w: a lookup secret is required for the keys without key ID", ErrInvalidApiKeyStore)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
+	if err != nil {
+		return ApiKeyEntry{}, fmt.Errorf("hashing API key: %w", err)
+	}
+	return ApiKeyEntry{ID: id, Hash: string(hash), UserID: userID}, nil
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+func newTestApiKey(t *testing.T, userID string) (string, ApiKeyEntry) {
+	t.Helper()
+	key, id, err := GenerateApiKey()
+	require.NoError(t, err)
+	entry, err := HashApiKey(key, userID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, id, entry.ID)
+	// the default cost is too slow for the reload loops under the race detector
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	require.NoError(t, err)
+	entry.Hash = string(hash)
+	return key, entry
+}
+
+func apiKeyRequest(key string) *http.Request {
+	return authRequest(map[string]string{DefaultXApiKeyHeaderName: key})
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, id, err := GenerateApiKey()
+	require.NoError(t, err)
+	assert.Regexp(t, `^ak_[0-9a-f]{16}_[A-Za-z0-9_-]{43}$`, key)
+	assert.Equal(t, id, ApiKeyID(key, nil))
+
+	other, _, err := GenerateApiKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	assert.NotEqual(t, ApiKeyID("legacy-key", []byte("a")), ApiKeyID("legacy-key", []byte("b")))
+	assert.Empty(t, ApiKeyID("legacy-key", nil))
+}
+
+func TestApiKeyStore(t *testing.T) {
+	key, entry := newTestApiKey(t, "billing")
+	entry.Roles = []string{"admin"}
+
+	t.Run("authenticates the keys of the config", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthApiKeysKeysKey, []map[string]any{{
+			"id":      entry.ID,
+			"hash":    entry.Hash,
+			"user_id": entry.UserID,
+			"roles":   entry.Roles,
+		}})
+		store, err := ApiKeyStoreFromConfig()
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		whoAmI(ApiKeyStoreAuthenticationFunc(store, "")).ServeHTTP(w, apiKeyRequest(key))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "billing api_key", w.Body.String())
+
+		w = httptest.NewRecorder()
+		handler := AuthenticationMiddleware(ApiKeyStoreAuthenticationFunc(store, ""))(RequireRoles("admin")(okHandler()))
+		handler.ServeHTTP(w, apiKeyRequest(key))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		store, err := NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{entry}})
+		require.NoError(t, err)
+		authFunc := ApiKeyStoreAuthenticationFunc(store, "")
+
+		unknown, _, err := GenerateApiKey()
+		require.NoError(t, err)
+		for name, k := range map[string]string{
+			"unknown key":        unknown,
+			"wrong secret":       key[:len(key)-1] + "x",
+			"key without key ID": "legacy-key",
+		} {
+			_, err := authFunc(apiKeyRequest(k))
+			assert.ErrorIs(t, err, ErrNotAuthorized, name)
+		}
+		_, err = authFunc(authRequest(nil))
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+
+	t.Run("checks the validity period", func(t *testing.T) {
+		e := entry
+		e.NotBefore = "2026-01-01T00:00:00Z"
+		e.ExpiresAt = "2026-07-01T00:00:00Z"
+		store, err := NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{e}})
+		require.NoError(t, err)
+
+		_, err = store.Authenticate(key, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+		assert.NoError(t, err)
+		_, err = store.Authenticate(key, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+		_, err = store.Authenticate(key, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("indexes the keys without key ID by lookup token", func(t *testing.T) {
+		secret := []byte("lookup-secret")
+		legacy, err := HashApiKey("legacy-key", "legacy", secret)
+		require.NoError(t, err)
+		store, err := NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{legacy}, LookupSecret: string(secret)})
+		require.NoError(t, err)
+
+		user, err := store.Authenticate("legacy-key", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, "legacy", user.UserID())
+
+		_, err = HashApiKey("legacy-key", "legacy", nil)
+		assert.ErrorIs(t, err, ErrInvalidApiKeyStore)
+		store, err = NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{legacy}})
+		require.NoError(t, err)
+		_, err = store.Authenticate("legacy-key", time.Now())
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("reloads the file atomically when it changes", func(t *testing.T) {
+		rotated, rotatedEntry := newTestApiKey(t, "billing")
+		file := filepath.Join(t.TempDir(), "api-keys.yaml")
+		writeKeys := func(entries ...ApiKeyEntry) {
+			content := "keys:\n"
+			for _, e := range entries {
+				content += "  - id: " + e.ID + "\n    hash: \"" + e.Hash + "\"\n    user_id: " + e.UserID + "\n    expires_at: 2099-01-01T00:00:00Z\n"
+			}
+			require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+			future := time.Now().Add(time.Minute * time.Duration(len(entries)))
+			require.NoError(t, os.Chtimes(file, future, future))
+		}
+		writeKeys(entry)
+		store, err := NewApiKeyStore(ApiKeyStoreConfig{File: file, ReloadInterval: time.Millisecond})
+		require.NoError(t, err)
+		_, err = store.Authenticate(key, time.Now())
+		require.NoError(t, err)
+
+		// both keys are valid during the rotation
+		writeKeys(entry, rotatedEntry)
+		assert.Eventually(t, func() bool {
+			_, err := store.Authenticate(rotated, time.Now())
+			return err == nil
+		}, time.Second, 5*time.Millisecond)
+		_, err = store.Authenticate(key, time.Now())
+		assert.NoError(t, err)
+
+		// an invalid file keeps the current keys
+		require.NoError(t, os.WriteFile(file, []byte("keys:\n  - id: broken\n"), 0o600))
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(file, future, future))
+		time.Sleep(5 * time.Millisecond)
+		_, err = store.Authenticate(rotated, time.Now())
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects invalid entries", func(t *testing.T) {
+		for name, e := range map[string]ApiKeyEntry{
+			"no user":        {ID: entry.ID, Hash: entry.Hash},
+			"plain key":      {ID: entry.ID, Hash: key, UserID: "billing"},
+			"invalid expiry": {ID: entry.ID, Hash: entry.Hash, UserID: "billing", ExpiresAt: "tomorrow"},
+			"invalid nbf":    {ID: entry.ID, Hash: entry.Hash, UserID: "billing", NotBefore: "2026-01-01"},
+			"missing key ID": {Hash: entry.Hash, UserID: "billing"},
+		} {
+			_, err := NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{e}})
+			assert.ErrorIs(t, err, ErrInvalidApiKeyStore, name)
+		}
+		_, err := NewApiKeyStore(ApiKeyStoreConfig{Keys: []ApiKeyEntry{entry, entry}})
+		assert.ErrorIs(t, err, ErrInvalidApiKeyStore)
+	})
+
+	t.Run("returns the config decode errors", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthApiKeysKeysKey, []map[string]any{{
+			"id":      entry.ID,
+			"hash":    entry.Hash,
+			"user_id": entry.UserID,
+			"roles":   map[string]any{"admin": true},
+		}})
+		store, err := ApiKeyStoreFromConfig()
+		assert.ErrorIs(t, err, ErrInvalidApiKeyStore)
+		assert.Nil(t, store)
+	})
+}
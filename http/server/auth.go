@@ -7,15 +7,42 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrNotAuthorized = errors.New("unauthorized")
+	// ErrNoCredentials is returned when the request presents no credentials
+	// for the authentication method, letting AnyOf try the next one.
+	ErrNoCredentials = fmt.Errorf("%w: no credentials", ErrNotAuthorized)
 )
 
+// ChallengeError is an authentication error asking the client to
+// authenticate with the scheme of Challenge, sent by the
+// AuthenticationMiddleware as the `WWW-Authenticate` header.
+type ChallengeError struct {
+	Challenge string
+	Err       error
+}
+
+func (e *ChallengeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ChallengeError) Unwrap() error {
+	return e.Err
+}
+
 // User represents a user.
 type User interface {
 	UserID() string
@@ -26,6 +53,7 @@ type ctxUserKey string
 
 const (
 	userKey                  ctxUserKey = "user"
+	authMethodKey            ctxUserKey = "auth_method"
 	DefaultXApiKeyHeaderName string     = "X-Api-Key"
 )
 
@@ -130,6 +158,10 @@ func defineHeaderName(headerName string) string {
 func SingleUserApiKeyAuthenticationFunc(apiKey, headerName string, user User) UserAuthenticationFunc {
 	headerName = defineHeaderName(headerName)
 	return func(r *http.Request) (User, error) {
+		if apiKey != "" && r.Header.Get(headerName) == "" {
+			return nil, ErrNoCredentials
+		}
+		setAuthAttempt(r, AuthMethodAPIKey, headerName, credentialFingerprint(r.Header.Get(headerName)))
 		if apiKey != "" && r.Header.Get(headerName) != apiKey {
 			return nil, ErrNotAuthorized
 		}
@@ -144,9 +176,10 @@ func MultipleUserApiKeyAuthenticationFunc(authData ApiKeyMap, headerName string)
 		apiKeyHeaderValue := r.Header.Get(headerName)
 
 		if apiKeyHeaderValue == "" {
-			return nil, ErrNotAuthorized
+			return nil, ErrNoCredentials
 		}
 
+		setAuthAttempt(r, AuthMethodAPIKey, headerName, credentialFingerprint(apiKeyHeaderValue))
 		if u, ok := authData.get(apiKeyHeaderValue); ok {
 			return u, nil
 		}
@@ -154,28 +187,144 @@ func MultipleUserApiKeyAuthenticationFunc(authData ApiKeyMap, headerName string)
 	}
 }
 
+// Results of the authentication attempts, recorded as the `auth.result`
+// attribute of the span and of the `http.server.auth.attempts` metric.
+const (
+	AuthResultSuccess       = "success"
+	AuthResultFailure       = "failure"
+	AuthResultNoCredentials = "no_credentials"
+	AuthResultThrottled     = "throttled"
+)
+
+// AuthMiddlewareOption configures the AuthenticationMiddleware.
+type AuthMiddlewareOption func(*authMiddleware)
+
+// WithAuthFailureLimiter defines the limiter throttling the clients failing
+// to authenticate (see AuthFailureLimiterFromConfig), disabled when nil, the
+// default. A limiter may be shared by several middlewares.
+func WithAuthFailureLimiter(limiter *AuthFailureLimiter) AuthMiddlewareOption {
+	return func(m *authMiddleware) {
+		m.limiter = limiter
+	}
+}
+
+// WithAuthMeterProvider defines the meter provider creating the
+// authentication metrics (the global one by default).
+func WithAuthMeterProvider(mp metric.MeterProvider) AuthMiddlewareOption {
+	return func(m *authMiddleware) {
+		m.meterProvider = mp
+	}
+}
+
+type authMiddleware struct {
+	limiter       *AuthFailureLimiter
+	meterProvider metric.MeterProvider
+	attempts      metric.Int64Counter
+}
+
 // AuthenticationMiddleware is a middleware that authenticates the user through the given UserAuthenticationFunc
-// and sets the user in the context.
-func AuthenticationMiddleware(authFunc UserAuthenticationFunc) func(http.Handler) http.Handler {
+// and sets the user and the authentication method in the context, the method also being recorded as the
+// `auth.method` attribute of the span.
+//
+// Each attempt is counted by the `http.server.auth.attempts` metric, by method and result, and the failures are
+// audit logged with the client IP and the header of the credentials, never the credentials. Once the
+// AuthFailureLimiter blocks the client IP or the credentials, requests get a 429 response with `Retry-After`.
+func AuthenticationMiddleware(authFunc UserAuthenticationFunc, opts ...AuthMiddlewareOption) func(http.Handler) http.Handler {
+	m := &authMiddleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	mp := m.meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	attempts, err := mp.Meter(serverMeterName).Int64Counter(
+		"http.server.auth.attempts",
+		metric.WithDescription("Number of authentication attempts"),
+		metric.WithUnit("{attempt}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	m.attempts = attempts
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := m.limiter.clientIP(r)
+			method := &authMethod{}
+			if retryAfter := m.limiter.blocked(limiterKeys(ip, method)...); retryAfter > 0 {
+				m.throttle(w, r, method, ip, retryAfter)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), authMethodKey, method))
 			user, err := authFunc(r)
-			if err != nil {
-				if errors.Is(err, ErrNotAuthorized) {
-					http.Error(w, ErrNotAuthorized.Error(), http.StatusUnauthorized)
-				}
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+			if err == nil && user == nil {
+				err = ErrNoCredentials
+			}
+			keys := limiterKeys(ip, method)
+			if retryAfter := m.limiter.blocked(keys...); retryAfter > 0 {
+				m.throttle(w, r, method, ip, retryAfter)
 				return
 			}
-			if user == nil {
-				http.Error(w, ErrNotAuthorized.Error(), http.StatusUnauthorized)
+			if err != nil {
+				m.fail(w, r, method, ip, err)
+				if !errors.Is(err, ErrNoCredentials) {
+					m.limiter.fail(keys...)
+				}
 				return
 			}
+			m.limiter.succeed(keys[1:]...)
+			m.record(r, method, AuthResultSuccess)
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
 		})
 	}
 }
 
+// record counts the attempt and sets its attributes on the span.
+func (m *authMiddleware) record(r *http.Request, method *authMethod, result string) {
+	name := method.name
+	if name == "" {
+		name = "none"
+	}
+	attrs := []attribute.KeyValue{attribute.String("auth.method", name), attribute.String("auth.result", result)}
+	if m.attempts != nil {
+		m.attempts.Add(r.Context(), 1, metric.WithAttributes(attrs...))
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attrs...)
+}
+
+func (m *authMiddleware) fail(w http.ResponseWriter, r *http.Request, method *authMethod, ip string, err error) {
+	result := AuthResultFailure
+	level := slog.LevelWarn
+	if errors.Is(err, ErrNoCredentials) {
+		result = AuthResultNoCredentials
+		level = slog.LevelDebug
+	}
+	m.record(r, method, result)
+	slog.Log(r.Context(), level, "authentication failed",
+		"auth_method", method.name, "client_ip", ip, "header", method.header,
+		"method", r.Method, "path", r.URL.Path, "error", err)
+
+	var challenge *ChallengeError
+	if errors.As(err, &challenge) {
+		w.Header().Set("WWW-Authenticate", challenge.Challenge)
+	}
+	msg := err.Error()
+	if errors.Is(err, ErrNotAuthorized) {
+		msg = ErrNotAuthorized.Error()
+	}
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+func (m *authMiddleware) throttle(w http.ResponseWriter, r *http.Request, method *authMethod, ip string, retryAfter time.Duration) {
+	m.record(r, method, AuthResultThrottled)
+	slog.WarnContext(r.Context(), "authentication throttled",
+		"auth_method", method.name, "client_ip", ip, "header", method.header,
+		"method", r.Method, "path", r.URL.Path, "retry_after", retryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeProblem(w, r, http.StatusTooManyRequests, "too many failed authentication attempts")
+}
+
 // AuthenticatedUserFromContext returns the authenticated user from the context.
 func AuthenticatedUserFromContext(ctx context.Context) User {
 	value := ctx.Value(userKey)
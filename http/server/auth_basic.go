@@ -0,0 +1,251 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultBasicAuthRealm is the realm of the `WWW-Authenticate` challenge
+	// when not configured.
+	DefaultBasicAuthRealm = "Restricted"
+	// DefaultHtpasswdReloadInterval is how often the htpasswd file is checked
+	// for changes when not configured.
+	DefaultHtpasswdReloadInterval = 10 * time.Second
+
+	htpasswdSHAPrefix = "{SHA}"
+)
+
+// ErrInvalidBasicAuthConfig is returned when the HTTP Basic authentication
+// configuration is invalid.
+var ErrInvalidBasicAuthConfig = errors.New("invalid basic authentication configuration")
+
+// BasicAuthConfig is the HTTP Basic authentication configuration (see the
+// `http.server.auth.basic.*` config keys).
+type BasicAuthConfig = configs.BasicAuth
+
+// BasicUser is the user authenticated by BasicAuthenticationFunc.
+type BasicUser struct {
+	Username string
+}
+
+func (u BasicUser) UserID() string {
+	return u.Username
+}
+
+func (u BasicUser) UserData() map[string]any {
+	return map[string]any{"username": u.Username}
+}
+
+// BasicAuthenticationFunc authenticates the users of the htpasswd file
+// configured by the `http.server.auth.basic.*` config keys (see
+// NewBasicAuthenticationFunc).
+func BasicAuthenticationFunc() (UserAuthenticationFunc, error) {
+	return NewBasicAuthenticationFunc(configs.GetBasicAuth())
+}
+
+// NewBasicAuthenticationFunc authenticates the users of the htpasswd file of
+// cfg, reloaded when it changes, from the HTTP Basic `Authorization` header.
+// The bcrypt (`$2y$`) and SHA (`{SHA}`) entries are supported, other ones are
+// skipped. Failures return a ChallengeError, so the AuthenticationMiddleware
+// sends the `WWW-Authenticate` header with the realm of cfg.
+//
+// Unknown users are compared with a dummy hash of the type of the file
+// entries (bcrypt with their highest cost when any, SHA otherwise), so they
+// take as long as known ones. In a file mixing both types, the SHA users
+// answer faster and can be told apart from the unknown ones.
+func NewBasicAuthenticationFunc(cfg BasicAuthConfig) (UserAuthenticationFunc, error) {
+	if cfg.HtpasswdFile == "" {
+		return nil, fmt.Errorf("%w: the htpasswd file is required", ErrInvalidBasicAuthConfig)
+	}
+	users, err := newHtpasswdReloader(cfg.HtpasswdFile, orDefault(cfg.ReloadInterval, DefaultHtpasswdReloadInterval))
+	if err != nil {
+		return nil, err
+	}
+	challengeValue := "Basic realm=" + strconv.Quote(orDefault(cfg.Realm, DefaultBasicAuthRealm)) + `, charset="UTF-8"`
+	challenge := &ChallengeError{Challenge: challengeValue, Err: ErrNotAuthorized}
+	noCredentials := &ChallengeError{Challenge: challengeValue, Err: ErrNoCredentials}
+
+	return func(r *http.Request) (User, error) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return nil, noCredentials
+		}
+		setAuthAttempt(r, AuthMethodBasic, "Authorization", credentialFingerprint(username))
+		hash, found := users.hash(username)
+		// unknown users are compared with the dummy hash
+		if valid := verifyHtpasswd(hash, password); !valid || !found {
+			return nil, challenge
+		}
+		return BasicUser{Username: username}, nil
+	}, nil
+}
+
+func verifyHtpasswd(hash, password string) bool {
+	if strings.HasPrefix(hash, htpasswdSHAPrefix) {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(hash[len(htpasswdSHAPrefix):])) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// parseHtpasswd returns the password hashes by user of the htpasswd file,
+// skipping the unsupported entries.
+func parseHtpasswd(data []byte) map[string]string {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			slog.Warn("skipping malformed htpasswd entry", "line", n)
+			continue
+		}
+		if !strings.HasPrefix(hash, htpasswdSHAPrefix) && !isBcryptHash(hash) {
+			slog.Warn("skipping htpasswd entry with an unsupported hash, only bcrypt and SHA are supported", "user", username)
+			continue
+		}
+		users[username] = hash
+	}
+	return users
+}
+
+// dummyBcryptHashes holds the dummy bcrypt hashes by cost, generated once.
+var dummyBcryptHashes sync.Map
+
+// dummyHtpasswdHash returns a hash of the type of the entries of users, to be
+// compared for the unknown users: a bcrypt one with the highest cost of the
+// entries when any, else a SHA one.
+func dummyHtpasswdHash(users map[string]string) (string, error) {
+	cost := 0
+	for _, hash := range users {
+		if c, err := bcrypt.Cost([]byte(hash)); err == nil {
+			cost = max(cost, c)
+		}
+	}
+	if cost == 0 {
+		sum := sha1.Sum([]byte("dummy password"))
+		return htpasswdSHAPrefix + base64.StdEncoding.EncodeToString(sum[:]), nil
+	}
+	if hash, ok := dummyBcryptHashes.Load(cost); ok {
+		return hash.(string), nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), cost)
+	if err != nil {
+		return "", fmt.Errorf("generating bcrypt hash: %w", err)
+	}
+	actual, _ := dummyBcryptHashes.LoadOrStore(cost, string(hash))
+	return actual.(string), nil
+}
+
+func isBcryptHash(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// htpasswdUsers are the password hashes of the users of an htpasswd file.
+type htpasswdUsers struct {
+	hashes map[string]string
+	// dummy is the hash compared for the unknown users.
+	dummy string
+}
+
+// htpasswdReloader loads the htpasswd file again when it changes, checking
+// it at most once per interval during the requests. The users are replaced
+// atomically, so the requests never wait for a reload.
+type htpasswdReloader struct {
+	file     string
+	interval time.Duration
+
+	users atomic.Pointer[htpasswdUsers]
+
+	// mu guards the file checks
+	mu        sync.Mutex
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newHtpasswdReloader(file string, interval time.Duration) (*htpasswdReloader, error) {
+	r := &htpasswdReloader{file: file, interval: interval}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading htpasswd file: %w", err)
+	}
+	if err := r.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *htpasswdReloader) load(modTime time.Time) error {
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		return fmt.Errorf("reading htpasswd file: %w", err)
+	}
+	hashes := parseHtpasswd(data)
+	dummy, err := dummyHtpasswdHash(hashes)
+	if err != nil {
+		return err
+	}
+	r.users.Store(&htpasswdUsers{hashes: hashes, dummy: dummy})
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+	return nil
+}
+
+// reloadIfChanged loads the file again when it changed, checking it at most
+// once per interval. A concurrent check is not waited for.
+func (r *htpasswdReloader) reloadIfChanged() {
+	if !r.mu.TryLock() {
+		return
+	}
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < r.interval {
+		return
+	}
+	r.checkedAt = time.Now()
+	info, err := os.Stat(r.file)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return
+	}
+	if err := r.load(info.ModTime()); err != nil {
+		slog.Error("failed to reload htpasswd file, keeping the current users", "file", r.file, "error", err)
+		return
+	}
+	slog.Info("htpasswd file reloaded", "file", r.file, "users", len(r.users.Load().hashes))
+}
+
+// hash returns the hash of the user, the dummy one when it is unknown.
+func (r *htpasswdReloader) hash(username string) (string, bool) {
+	r.reloadIfChanged()
+	users := r.users.Load()
+	hash, ok := users.hashes[username]
+	if !ok {
+		return users.dummy, false
+	}
+	return hash, true
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+func htpasswdLine(t *testing.T, username, password string, bcryptHash bool) string {
+	t.Helper()
+	if !bcryptHash {
+		sum := sha1.Sum([]byte(password))
+		return username + ":{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return username + ":" + string(hash) + "\n"
+}
+
+func basicRequest(username, password string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.SetBasicAuth(username, password)
+	return req
+}
+
+func TestBasicAuthenticationFunc(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".htpasswd")
+	require.NoError(t, os.WriteFile(file, []byte("# users\n"+
+		htpasswdLine(t, "alice", "alice-pass", true)+
+		htpasswdLine(t, "bob", "bob-pass", false)+
+		"carol:$apr1$abc$def\n"+
+		"malformed\n"), 0o600))
+
+	t.Run("authenticates the bcrypt and SHA entries", func(t *testing.T) {
+		authFunc, err := NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: file})
+		require.NoError(t, err)
+
+		for username, password := range map[string]string{"alice": "alice-pass", "bob": "bob-pass"} {
+			user, err := authFunc(basicRequest(username, password))
+			require.NoError(t, err, username)
+			assert.Equal(t, username, user.UserID())
+			assert.Equal(t, username, user.UserData()["username"])
+		}
+	})
+
+	t.Run("rejects invalid credentials", func(t *testing.T) {
+		authFunc, err := NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: file})
+		require.NoError(t, err)
+
+		for name, req := range map[string]*http.Request{
+			"wrong bcrypt password": basicRequest("alice", "bob-pass"),
+			"wrong SHA password":    basicRequest("bob", "alice-pass"),
+			"unknown user":          basicRequest("dave", "alice-pass"),
+			"unsupported hash":      basicRequest("carol", "carol-pass"),
+			"no credentials":        httptest.NewRequest(http.MethodGet, "http://example.com", nil),
+		} {
+			_, err := authFunc(req)
+			assert.ErrorIs(t, err, ErrNotAuthorized, name)
+		}
+	})
+
+	t.Run("compares the unknown users with a hash of the entries type", func(t *testing.T) {
+		dummy, err := dummyHtpasswdHash(parseHtpasswd([]byte(htpasswdLine(t, "bob", "bob-pass", false))))
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(dummy, htpasswdSHAPrefix))
+
+		users := parseHtpasswd([]byte(htpasswdLine(t, "alice", "alice-pass", true) + htpasswdLine(t, "bob", "bob-pass", false)))
+		dummy, err = dummyHtpasswdHash(users)
+		require.NoError(t, err)
+		cost, err := bcrypt.Cost([]byte(dummy))
+		require.NoError(t, err)
+		assert.Equal(t, bcrypt.MinCost, cost)
+
+		again, err := dummyHtpasswdHash(users)
+		require.NoError(t, err)
+		assert.Equal(t, dummy, again, "the hash should be generated once per cost")
+
+		// the dummy password does not authenticate unknown users
+		authFunc, err := NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: file})
+		require.NoError(t, err)
+		_, err = authFunc(basicRequest("dave", "dummy password"))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("sends the challenge with the realm", func(t *testing.T) {
+		authFunc, err := NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: file, Realm: "internal tools"})
+		require.NoError(t, err)
+		handler := AuthenticationMiddleware(authFunc)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, basicRequest("alice", "wrong"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="internal tools", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, basicRequest("alice", "alice-pass"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("reloads the file when it changes", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), ".htpasswd")
+		require.NoError(t, os.WriteFile(file, []byte(htpasswdLine(t, "alice", "alice-pass", false)), 0o600))
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthBasicHtpasswdFileKey, file)
+		viper.Set(configs.HTTPServerAuthBasicReloadIntervalKey, "1ms")
+		authFunc, err := BasicAuthenticationFunc()
+		require.NoError(t, err)
+
+		_, err = authFunc(basicRequest("alice", "alice-pass"))
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(file, []byte(htpasswdLine(t, "alice", "rotated", false)), 0o600))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(file, future, future))
+		assert.Eventually(t, func() bool {
+			_, err := authFunc(basicRequest("alice", "rotated"))
+			return err == nil
+		}, time.Second, 5*time.Millisecond)
+		_, err = authFunc(basicRequest("alice", "alice-pass"))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("requires an existing htpasswd file", func(t *testing.T) {
+		_, err := NewBasicAuthenticationFunc(BasicAuthConfig{})
+		assert.ErrorIs(t, err, ErrInvalidBasicAuthConfig)
+		_, err = NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: "missing"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// ClientCertAuthConfig is the client certificate authentication configuration
+// (see the `http.server.auth.client_cert.*` config keys).
+type ClientCertAuthConfig = configs.ClientCertAuth
+
+// ClientCertMapper maps a verified client certificate to a user.
+type ClientCertMapper func(cert *x509.Certificate) (User, error)
+
+// ClientCertUser is the user of a client certificate mapped by DefaultClientCertMapper.
+type ClientCertUser struct {
+	// ID is the first SAN URI (like a SPIFFE ID), or the subject common name.
+	ID         string
+	CommonName string
+	URIs       []string
+	DNSNames   []string
+	Serial     string
+}
+
+func (u ClientCertUser) UserID() string {
+	return u.ID
+}
+
+func (u ClientCertUser) UserData() map[string]any {
+	return map[string]any{
+		"common_name": u.CommonName,
+		"uris":        u.URIs,
+		"dns_names":   u.DNSNames,
+		"serial":      u.Serial,
+	}
+}
+
+// DefaultClientCertMapper maps the certificate to a ClientCertUser.
+func DefaultClientCertMapper(cert *x509.Certificate) (User, error) {
+	u := ClientCertUser{
+		ID:         cert.Subject.CommonName,
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		Serial:     cert.SerialNumber.String(),
+	}
+	for _, uri := range cert.URIs {
+		u.URIs = append(u.URIs, uri.String())
+	}
+	if len(u.URIs) > 0 {
+		u.ID = u.URIs[0]
+	}
+	return u, nil
+}
+
+// ClientCertAuthenticationFunc authenticates the user from the client
+// certificate of the TLS connection, configured by the
+// `http.server.auth.client_cert.*` config keys (see NewClientCertAuthenticationFunc).
+func ClientCertAuthenticationFunc(mapper ClientCertMapper) (UserAuthenticationFunc, error) {
+	return NewClientCertAuthenticationFunc(mapper, configs.GetClientCertAuth())
+}
+
+// NewClientCertAuthenticationFunc authenticates the user from the client
+// certificate of the TLS connection, verified against the CA file of cfg (or
+// by the TLS handshake when empty) and allowed by its common name and SAN URI
+// allow-lists (any verified certificate when both are empty). The certificate
+// is mapped to the user by mapper (DefaultClientCertMapper when nil).
+func NewClientCertAuthenticationFunc(mapper ClientCertMapper, cfg ClientCertAuthConfig) (UserAuthenticationFunc, error) {
+	if mapper == nil {
+		mapper = DefaultClientCertMapper
+	}
+	var roots *x509.CertPool
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client certificate CA file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificate found in %s", ErrInvalidTLSConfig, cfg.CAFile)
+		}
+	}
+
+	return func(r *http.Request) (User, error) {
+		cert, err := verifiedClientCert(r, roots)
+		if errors.Is(err, ErrNoCredentials) {
+			return nil, err
+		}
+		setAuthAttempt(r, AuthMethodClientCert, "", clientCertFingerprint(r.TLS.PeerCertificates[0]))
+		if err == nil {
+			err = allowClientCert(cert, cfg)
+		}
+		if err != nil {
+			slog.DebugContext(r.Context(), "client certificate authentication failed", "error", err)
+			return nil, ErrNotAuthorized
+		}
+		user, err := mapper(cert)
+		if err != nil {
+			slog.DebugContext(r.Context(), "client certificate mapping failed", "error", err)
+			return nil, ErrNotAuthorized
+		}
+		return user, nil
+	}, nil
+}
+
+// clientCertFingerprint returns the SHA-256 fingerprint of the certificate,
+// identifying it for the AuthFailureLimiter.
+func clientCertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifiedClientCert returns the client certificate of the request, verified
+// against roots, or by the TLS handshake when roots is nil.
+func verifiedClientCert(r *http.Request, roots *x509.CertPool) (*x509.Certificate, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, ErrNoCredentials
+	}
+	cert := r.TLS.PeerCertificates[0]
+	if roots == nil {
+		if len(r.TLS.VerifiedChains) == 0 {
+			return nil, fmt.Errorf("client certificate not verified by the TLS handshake")
+		}
+		return cert, nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, fmt.Errorf("verifying client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func allowClientCert(cert *x509.Certificate, cfg ClientCertAuthConfig) error {
+	if len(cfg.AllowedCommonNames) == 0 && len(cfg.AllowedURIs) == 0 {
+		return nil
+	}
+	if slices.Contains(cfg.AllowedCommonNames, cert.Subject.CommonName) {
+		return nil
+	}
+	for _, uri := range cert.URIs {
+		for _, allowed := range cfg.AllowedURIs {
+			if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(uri.String(), prefix) || uri.String() == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate %q is not allowed", cert.Subject.CommonName)
+}
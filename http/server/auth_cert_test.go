@@ -0,0 +1,191 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+func clientCertRequest(certs ...tls.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	req.TLS = &tls.ConnectionState{}
+	for _, c := range certs {
+		req.TLS.PeerCertificates = append(req.TLS.PeerCertificates, c.Leaf)
+	}
+	return req
+}
+
+func TestClientCertAuthenticationFunc(t *testing.T) {
+	ca := newTestCA(t)
+	spiffeID := "spiffe://example.org/ns/prod/sa/billing"
+
+	t.Run("maps the SPIFFE ID of a certificate signed by the CA", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		user, err := authFunc(clientCertRequest(ca.issue(t, "billing", x509.ExtKeyUsageClientAuth, spiffeID)))
+		require.NoError(t, err)
+		assert.Equal(t, spiffeID, user.UserID())
+		assert.Equal(t, "billing", user.UserData()["common_name"])
+		assert.Equal(t, []string{spiffeID}, user.UserData()["uris"])
+	})
+
+	t.Run("rejects certificates of another CA", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		_, err = authFunc(clientCertRequest(newTestCA(t).issue(t, "billing", x509.ExtKeyUsageClientAuth)))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("rejects server certificates", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		_, err = authFunc(clientCertRequest(ca.issue(t, "billing", x509.ExtKeyUsageServerAuth)))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("rejects requests without certificate", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		_, err = authFunc(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+		_, err = authFunc(clientCertRequest())
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("requires the chain verified by the handshake without CA file", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{})
+		require.NoError(t, err)
+
+		cert := ca.issue(t, "billing", x509.ExtKeyUsageClientAuth)
+		req := clientCertRequest(cert)
+		_, err = authFunc(req)
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{cert.Leaf, ca.cert}}
+		user, err := authFunc(req)
+		require.NoError(t, err)
+		assert.Equal(t, "billing", user.UserID())
+	})
+
+	t.Run("applies the allow-lists", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{
+			CAFile:             ca.file,
+			AllowedCommonNames: []string{"reporting"},
+			AllowedURIs:        []string{"spiffe://example.org/ns/prod/*"},
+		})
+		require.NoError(t, err)
+
+		for cn, uris := range map[string][]string{
+			"reporting": nil,
+			"billing":   {spiffeID},
+		} {
+			_, err := authFunc(clientCertRequest(ca.issue(t, cn, x509.ExtKeyUsageClientAuth, uris...)))
+			assert.NoError(t, err, cn)
+		}
+		for cn, uris := range map[string][]string{
+			"billing": {"spiffe://example.org/ns/dev/sa/billing"},
+			"other":   nil,
+		} {
+			_, err := authFunc(clientCertRequest(ca.issue(t, cn, x509.ExtKeyUsageClientAuth, uris...)))
+			assert.ErrorIs(t, err, ErrNotAuthorized, cn)
+		}
+	})
+
+	t.Run("uses the mapper", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(func(cert *x509.Certificate) (User, error) {
+			return testUser{id: "svc-" + cert.Subject.CommonName}, nil
+		}, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		user, err := authFunc(clientCertRequest(ca.issue(t, "billing", x509.ExtKeyUsageClientAuth)))
+		require.NoError(t, err)
+		assert.Equal(t, "svc-billing", user.UserID())
+	})
+
+	t.Run("does not expose the mapper errors", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(func(*x509.Certificate) (User, error) {
+			return nil, errors.New("querying the service registry: connection refused")
+		}, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+
+		req := clientCertRequest(ca.issue(t, "billing", x509.ExtKeyUsageClientAuth))
+		w := httptest.NewRecorder()
+		AuthenticationMiddleware(authFunc)(okHandler()).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotContains(t, w.Body.String(), "service registry")
+	})
+
+	t.Run("throttles the certificates failing authentication", func(t *testing.T) {
+		authFunc, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: ca.file})
+		require.NoError(t, err)
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 1})
+		handler := AuthenticationMiddleware(authFunc, WithAuthFailureLimiter(limiter))(okHandler())
+
+		rejected := newTestCA(t).issue(t, "billing", x509.ExtKeyUsageClientAuth)
+		for i, wantCode := range []int{http.StatusUnauthorized, http.StatusTooManyRequests} {
+			req := clientCertRequest(rejected)
+			req.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", i+1)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, wantCode, w.Code)
+		}
+
+		req := clientCertRequest(ca.issue(t, "billing", x509.ExtKeyUsageClientAuth))
+		req.RemoteAddr = "192.0.2.3:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("fails with an invalid CA file", func(t *testing.T) {
+		_, err := NewClientCertAuthenticationFunc(nil, ClientCertAuthConfig{CAFile: "missing.pem"})
+		assert.Error(t, err)
+	})
+
+	t.Run("authenticates the clients of the server from the config", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthClientCertAllowedURIsKey, []string{spiffeID})
+		authFunc, err := ClientCertAuthenticationFunc(nil)
+		require.NoError(t, err)
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /whoami", AuthenticationMiddleware(authFunc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, AuthenticatedUserFromContext(r.Context()).UserID())
+		})))
+		certFile, keyFile := writeKeyPair(t, t.TempDir(), ca.issue(t, "server", x509.ExtKeyUsageServerAuth))
+		url, _ := startTLSServer(t, t.Context(), mux, WithTLS(TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: ca.file,
+			ClientAuth:   configs.TLSClientAuthVerifyIfGiven,
+		}))
+
+		client := tlsClient(ca, &tls.Config{Certificates: []tls.Certificate{ca.issue(t, "billing", x509.ExtKeyUsageClientAuth, spiffeID)}})
+		resp, err := client.Get(url + "/whoami")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, spiffeID, string(body))
+
+		resp, err = tlsClient(ca, nil).Get(url + "/whoami")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
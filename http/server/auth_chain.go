@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Authentication methods recorded by the built-in UserAuthenticationFunc
+// implementations (see AuthenticationMethodFromContext).
+const (
+	AuthMethodAPIKey     = "api_key"
+	AuthMethodClientCert = "client_cert"
+	AuthMethodJWT        = "jwt"
+	AuthMethodBasic      = "basic"
+	AuthMethodAnonymous  = "anonymous"
+)
+
+// authMethod holds the method authenticating the request, set by the
+// UserAuthenticationFunc called by the AuthenticationMiddleware. The header
+// and credential of the attempt are reported when the authentication fails.
+type authMethod struct {
+	name string
+	// header is the request header of the credentials
+	header string
+	// credential identifies the credentials for the AuthFailureLimiter,
+	// without revealing them (a username or a key fingerprint)
+	credential string
+}
+
+func setAuthMethod(r *http.Request, name string) {
+	if m, ok := r.Context().Value(authMethodKey).(*authMethod); ok {
+		m.name = name
+	}
+}
+
+// setAuthAttempt records the method checking the credentials presented by
+// the request, before they are verified.
+func setAuthAttempt(r *http.Request, name, header, credential string) {
+	if m, ok := r.Context().Value(authMethodKey).(*authMethod); ok {
+		*m = authMethod{name: name, header: header, credential: credential}
+	}
+}
+
+// AuthenticationMethodFromContext returns the method that authenticated the
+// user (like `api_key` or `jwt`), empty when unknown.
+func AuthenticationMethodFromContext(ctx context.Context) string {
+	if m, ok := ctx.Value(authMethodKey).(*authMethod); ok {
+		return m.name
+	}
+	return ""
+}
+
+// WithAuthMethod names the authentication method of authFunc, recorded when
+// it authenticates the user.
+func WithAuthMethod(name string, authFunc UserAuthenticationFunc) UserAuthenticationFunc {
+	return func(r *http.Request) (User, error) {
+		user, err := authFunc(r)
+		if err == nil && user != nil {
+			setAuthMethod(r, name)
+		}
+		return user, err
+	}
+}
+
+// AnyOf authenticates the user with the first of authFuncs finding
+// credentials in the request. The next one is tried only when no credentials
+// are presented (ErrNoCredentials or a nil user), invalid credentials failing
+// the authentication. When none finds credentials, the challenges of the
+// ChallengeError returned are combined.
+func AnyOf(authFuncs ...UserAuthenticationFunc) UserAuthenticationFunc {
+	return func(r *http.Request) (User, error) {
+		var challenges []string
+		for _, authFunc := range authFuncs {
+			setAuthAttempt(r, "", "", "")
+			user, err := authFunc(r)
+			if err == nil && user != nil {
+				return user, nil
+			}
+			if err != nil && !errors.Is(err, ErrNoCredentials) {
+				return nil, err
+			}
+			var challenge *ChallengeError
+			if errors.As(err, &challenge) {
+				challenges = append(challenges, challenge.Challenge)
+			}
+		}
+		if len(challenges) > 0 {
+			return nil, &ChallengeError{Challenge: strings.Join(challenges, ", "), Err: ErrNoCredentials}
+		}
+		return nil, ErrNoCredentials
+	}
+}
+
+// AllOf authenticates the user with every one of authFuncs (like a client
+// certificate and a bearer token), returning the user of the first one. The
+// method recorded joins their methods with `+`.
+func AllOf(authFuncs ...UserAuthenticationFunc) UserAuthenticationFunc {
+	return func(r *http.Request) (User, error) {
+		var first User
+		var methods []string
+		for _, authFunc := range authFuncs {
+			setAuthAttempt(r, "", "", "")
+			user, err := authFunc(r)
+			if err != nil {
+				return nil, err
+			}
+			if user == nil {
+				return nil, ErrNoCredentials
+			}
+			if first == nil {
+				first = user
+			}
+			if method := AuthenticationMethodFromContext(r.Context()); method != "" {
+				methods = append(methods, method)
+			}
+		}
+		if first == nil {
+			return nil, ErrNoCredentials
+		}
+		setAuthMethod(r, strings.Join(methods, "+"))
+		return first, nil
+	}
+}
+
+// GuestUser is the anonymous user of the optional authentication routes.
+type GuestUser struct{}
+
+func (GuestUser) UserID() string {
+	return "guest"
+}
+
+func (GuestUser) UserData() map[string]any {
+	return map[string]any{}
+}
+
+// Optional makes the authentication optional: requests presenting no
+// credentials are authenticated as guest (GuestUser when nil) with the
+// `anonymous` method, while invalid credentials still fail.
+func Optional(authFunc UserAuthenticationFunc, guest User) UserAuthenticationFunc {
+	if guest == nil {
+		guest = GuestUser{}
+	}
+	return func(r *http.Request) (User, error) {
+		user, err := authFunc(r)
+		if (err == nil && user == nil) || errors.Is(err, ErrNoCredentials) {
+			setAuthMethod(r, AuthMethodAnonymous)
+			return guest, nil
+		}
+		return user, err
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tokenAuthFunc authenticates the `X-Token: valid` header.
+func tokenAuthFunc(r *http.Request) (User, error) {
+	switch r.Header.Get("X-Token") {
+	case "":
+		return nil, ErrNoCredentials
+	case "valid":
+		return testUser{id: "token-user"}, nil
+	default:
+		return nil, ErrNotAuthorized
+	}
+}
+
+// whoAmI serves the authenticated user ID and method.
+func whoAmI(authFunc UserAuthenticationFunc) http.Handler {
+	return AuthenticationMiddleware(authFunc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(AuthenticatedUserFromContext(r.Context()).UserID() + " " + AuthenticationMethodFromContext(r.Context())))
+	}))
+}
+
+func authRequest(headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestAnyOf(t *testing.T) {
+	handler := whoAmI(AnyOf(
+		SingleUserApiKeyAuthenticationFunc("api-key", "", testUser{id: "key-user"}),
+		WithAuthMethod("token", tokenAuthFunc),
+	))
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{name: "the first method", headers: map[string]string{DefaultXApiKeyHeaderName: "api-key"}, wantCode: http.StatusOK, wantBody: "key-user api_key"},
+		{name: "the next method without credentials for the first", headers: map[string]string{"X-Token": "valid"}, wantCode: http.StatusOK, wantBody: "token-user token"},
+		{name: "stops on invalid credentials", headers: map[string]string{DefaultXApiKeyHeaderName: "wrong", "X-Token": "valid"}, wantCode: http.StatusUnauthorized},
+		{name: "invalid credentials of the next method", headers: map[string]string{"X-Token": "wrong"}, wantCode: http.StatusUnauthorized},
+		{name: "no credentials", wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, authRequest(tt.headers))
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("combines the challenges", func(t *testing.T) {
+		challenge := func(c string) UserAuthenticationFunc {
+			return func(*http.Request) (User, error) {
+				return nil, &ChallengeError{Challenge: c, Err: ErrNoCredentials}
+			}
+		}
+		w := httptest.NewRecorder()
+		whoAmI(AnyOf(challenge(`Basic realm="a"`), tokenAuthFunc, challenge(`Bearer realm="b"`))).ServeHTTP(w, authRequest(nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="a", Bearer realm="b"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("records the method on the span", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, span := tp.Tracer("test").Start(t.Context(), "request")
+		req := authRequest(map[string]string{"X-Token": "valid"}).WithContext(ctx)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		span.End()
+
+		require.Len(t, recorder.Ended(), 1)
+		assert.Contains(t, recorder.Ended()[0].Attributes(), attribute.String("auth.method", "token"))
+	})
+}
+
+func TestAllOf(t *testing.T) {
+	handler := whoAmI(AllOf(
+		SingleUserApiKeyAuthenticationFunc("api-key", "", testUser{id: "key-user"}),
+		WithAuthMethod("token", tokenAuthFunc),
+	))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, authRequest(map[string]string{DefaultXApiKeyHeaderName: "api-key", "X-Token": "valid"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "key-user api_key+token", w.Body.String())
+
+	for _, headers := range []map[string]string{
+		{DefaultXApiKeyHeaderName: "api-key"},
+		{"X-Token": "valid"},
+		{DefaultXApiKeyHeaderName: "api-key", "X-Token": "wrong"},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, authRequest(headers))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, headers)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	t.Run("authenticates the guest without credentials", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		whoAmI(Optional(tokenAuthFunc, nil)).ServeHTTP(w, authRequest(nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "guest anonymous", w.Body.String())
+	})
+
+	t.Run("uses the given guest", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		whoAmI(Optional(tokenAuthFunc, testUser{id: "visitor"})).ServeHTTP(w, authRequest(nil))
+		assert.Equal(t, "visitor anonymous", w.Body.String())
+	})
+
+	t.Run("authenticates the user with credentials", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		whoAmI(Optional(WithAuthMethod("token", tokenAuthFunc), nil)).ServeHTTP(w, authRequest(map[string]string{"X-Token": "valid"}))
+		assert.Equal(t, "token-user token", w.Body.String())
+	})
+
+	t.Run("rejects invalid credentials", func(t *testing.T) {
+		_, err := Optional(tokenAuthFunc, nil)(authRequest(map[string]string{"X-Token": "wrong"}))
+		assert.True(t, errors.Is(err, ErrNotAuthorized))
+		assert.False(t, errors.Is(err, ErrNoCredentials))
+	})
+}
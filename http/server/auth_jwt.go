@@ -0,0 +1,352 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// Signing algorithms accepted by JWTAuthenticationFunc.
+const (
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmES256 = "ES256"
+	JWTAlgorithmEdDSA = "EdDSA"
+	JWTAlgorithmHS256 = "HS256"
+)
+
+const (
+	// DefaultJWTClockSkew is the tolerance applied to the `exp` and `nbf`
+	// claims when not configured.
+	DefaultJWTClockSkew = time.Minute
+	// DefaultJWTUserIDClaim is the claim identifying the user when not configured.
+	DefaultJWTUserIDClaim = "sub"
+
+	defaultJWKSFetchTimeout = 10 * time.Second
+)
+
+// ErrInvalidJWTConfig is returned when the JWT authentication configuration is invalid.
+var ErrInvalidJWTConfig = errors.New("invalid JWT authentication configuration")
+
+// JWTOptions configures the JWT bearer authentication.
+type JWTOptions struct {
+	// Issuer is the expected `iss` claim, not checked when empty.
+	Issuer string
+	// Audience are the accepted `aud` claims (one of them is required), not
+	// checked when empty.
+	Audience []string
+	// Algorithms are the accepted signing algorithms, by default RS256, ES256
+	// and EdDSA with a JWKS, and HS256 with a secret.
+	Algorithms []string
+	// ClockSkew is the tolerance applied to the `exp` and `nbf` claims.
+	ClockSkew time.Duration
+	// JWKSURL is the URL of the JWKS serving the verification keys, cached
+	// and refreshed every RefreshInterval or when a token is signed by an
+	// unknown key.
+	JWKSURL string
+	// JWKSFile is a static JWKS file of verification keys.
+	JWKSFile string
+	// RefreshInterval is how often the JWKS URL keys are refreshed.
+	RefreshInterval time.Duration
+	// Secret is the HS256 shared secret.
+	Secret []byte
+	// UserIDClaim is the claim identifying the user (`sub` by default).
+	UserIDClaim string
+	// HTTPClient fetches the JWKS (a client with a 10s timeout by default).
+	HTTPClient *http.Client
+}
+
+// JWTOptionsFromConfig returns the JWT authentication options defined by the
+// `http.server.auth.jwt.*` config keys.
+func JWTOptionsFromConfig() JWTOptions {
+	cfg := configs.GetJWTAuth()
+	opts := JWTOptions{
+		Issuer:          cfg.Issuer,
+		Audience:        cfg.Audience,
+		Algorithms:      cfg.Algorithms,
+		ClockSkew:       cfg.ClockSkew,
+		JWKSURL:         cfg.JWKSURL,
+		JWKSFile:        cfg.JWKSFile,
+		RefreshInterval: cfg.JWKSRefreshInterval,
+		UserIDClaim:     cfg.UserIDClaim,
+	}
+	if cfg.Secret != "" {
+		opts.Secret = []byte(cfg.Secret)
+	}
+	return opts
+}
+
+// JWTUser is the user of a JWT authenticated by JWTAuthenticationFunc.
+type JWTUser struct {
+	ID     string
+	Claims map[string]any
+}
+
+func (u JWTUser) UserID() string {
+	return u.ID
+}
+
+// UserData returns the claims of the token.
+func (u JWTUser) UserData() map[string]any {
+	return u.Claims
+}
+
+type jwtAuthenticator struct {
+	opts    JWTOptions
+	keySets []keySet
+}
+
+// JWTAuthenticationFunc authenticates the user from the `Authorization:
+// Bearer` JWT, verifying its signature with the keys of the JWKS URL, the
+// JWKS file or the secret of opts, and its `iss`, `aud`, `exp` and `nbf`
+// claims. The user is a JWTUser exposing the claims.
+func JWTAuthenticationFunc(opts JWTOptions) (UserAuthenticationFunc, error) {
+	a := &jwtAuthenticator{opts: opts}
+	a.opts.ClockSkew = orDefault(opts.ClockSkew, DefaultJWTClockSkew)
+	a.opts.UserIDClaim = orDefault(opts.UserIDClaim, DefaultJWTUserIDClaim)
+
+	if opts.JWKSURL != "" {
+		client := opts.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: defaultJWKSFetchTimeout}
+		}
+		a.keySets = append(a.keySets, &remoteKeySet{
+			url:      opts.JWKSURL,
+			client:   client,
+			interval: orDefault(opts.RefreshInterval, DefaultJWKSRefreshInterval),
+		})
+	}
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKSFile(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keySets = append(a.keySets, keys)
+	}
+	if len(opts.Secret) > 0 {
+		a.keySets = append(a.keySets, staticKeySet{{alg: JWTAlgorithmHS256, key: opts.Secret}})
+	}
+	if len(a.keySets) == 0 {
+		return nil, fmt.Errorf("%w: a JWKS URL, JWKS file or secret is required", ErrInvalidJWTConfig)
+	}
+
+	if len(a.opts.Algorithms) == 0 {
+		if opts.JWKSURL != "" || opts.JWKSFile != "" {
+			a.opts.Algorithms = append(a.opts.Algorithms, JWTAlgorithmRS256, JWTAlgorithmES256, JWTAlgorithmEdDSA)
+		}
+		if len(opts.Secret) > 0 {
+			a.opts.Algorithms = append(a.opts.Algorithms, JWTAlgorithmHS256)
+		}
+	}
+	for _, alg := range a.opts.Algorithms {
+		switch alg {
+		case JWTAlgorithmRS256, JWTAlgorithmES256, JWTAlgorithmEdDSA, JWTAlgorithmHS256:
+		default:
+			return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidJWTConfig, alg)
+		}
+	}
+
+	return func(r *http.Request) (User, error) {
+		user, err := a.authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			return nil, err
+		}
+		setAuthAttempt(r, AuthMethodJWT, "Authorization", "")
+		if err != nil {
+			slog.DebugContext(r.Context(), "JWT authentication failed", "error", err)
+			return nil, ErrNotAuthorized
+		}
+		return user, nil
+	}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (a *jwtAuthenticator) authenticate(r *http.Request) (User, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	if !slices.Contains(a.opts.Algorithms, header.Alg) {
+		return nil, fmt.Errorf("algorithm %q not accepted", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	if err := a.verify(r, header, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+	if err := a.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	id, _ := claims[a.opts.UserIDClaim].(string)
+	if id == "" {
+		return nil, fmt.Errorf("missing %s claim", a.opts.UserIDClaim)
+	}
+	return JWTUser{ID: id, Claims: claims}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verify checks the signature against the keys matching the key ID and
+// algorithm of the header.
+func (a *jwtAuthenticator) verify(r *http.Request, header jwtHeader, signed, sig []byte) error {
+	var errs []error
+	for _, set := range a.keySets {
+		keys, err := set.keys(r.Context(), header.Kid)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, k := range keys {
+			if header.Kid != "" && k.kid != "" && k.kid != header.Kid {
+				continue
+			}
+			if k.alg != "" && k.alg != header.Alg {
+				continue
+			}
+			if verifyJWTSignature(header.Alg, k.key, signed, sig) {
+				return nil
+			}
+		}
+	}
+	errs = append(errs, fmt.Errorf("no key verifying the %s signature of kid %q", header.Alg, header.Kid))
+	return errors.Join(errs...)
+}
+
+func verifyJWTSignature(alg string, key any, signed, sig []byte) bool {
+	switch alg {
+	case JWTAlgorithmRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		digest := sha256.Sum256(signed)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	case JWTAlgorithmES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		return ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	case JWTAlgorithmEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, sig)
+	case JWTAlgorithmHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		_, _ = mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	default:
+		return false
+	}
+}
+
+func (a *jwtAuthenticator) validateClaims(claims map[string]any, now time.Time) error {
+	exp, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if now.After(exp.Add(a.opts.ClockSkew)) {
+		return fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+	nbf, ok, err := numericDate(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(a.opts.ClockSkew).Before(nbf) {
+		return fmt.Errorf("token not valid before %s", nbf.Format(time.RFC3339))
+	}
+
+	if a.opts.Issuer != "" && claims["iss"] != a.opts.Issuer {
+		return fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if len(a.opts.Audience) > 0 && !slices.ContainsFunc(audiences(claims["aud"]), func(aud string) bool {
+		return slices.Contains(a.opts.Audience, aud)
+	}) {
+		return fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	return nil
+}
+
+func numericDate(claims map[string]any, name string) (time.Time, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	sec, frac := int64(n), n-float64(int64(n))
+	return time.Unix(sec, int64(frac*float64(time.Second))), true, nil
+}
+
+// audiences returns the `aud` claim, a string or an array of strings.
+func audiences(aud any) []string {
+	switch v := aud.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var auds []string
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+		return auds
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// testSigner signs JWTs with a private key, exposed as a JWK.
+type testSigner struct {
+	kid string
+	alg string
+	key any
+}
+
+func newTestSigner(t *testing.T, kid, alg string) testSigner {
+	t.Helper()
+	s := testSigner{kid: kid, alg: alg}
+	var err error
+	switch alg {
+	case JWTAlgorithmRS256:
+		s.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case JWTAlgorithmES256:
+		s.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case JWTAlgorithmEdDSA:
+		_, s.key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	require.NoError(t, err)
+	return s
+}
+
+func (s testSigner) jwk() map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "alg": s.alg, "use": "sig",
+			"n": enc(k.N.Bytes()), "e": enc([]byte{1, 0, 1})}
+	case *ecdsa.PrivateKey:
+		pub, _ := k.PublicKey.Bytes()
+		return map[string]string{"kty": "EC", "kid": s.kid, "crv": "P-256", "x": enc(pub[1:33]), "y": enc(pub[33:])}
+	case ed25519.PrivateKey:
+		return map[string]string{"kty": "OKP", "kid": s.kid, "crv": "Ed25519", "x": enc(k.Public().(ed25519.PublicKey))}
+	}
+	return nil
+}
+
+func jwksJSON(t *testing.T, signers ...testSigner) []byte {
+	t.Helper()
+	var keys []map[string]string
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func (s testSigner) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	enc := base64.RawURLEncoding.EncodeToString
+	header, err := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := enc(header) + "." + enc(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, ss *big.Int
+		r, ss, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		_, _ = mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	require.NoError(t, err)
+	return signed + "." + enc(sig)
+}
+
+// jwksServer serves the JWKS of the signers, counting the requests.
+type jwksServer struct {
+	mu       sync.Mutex
+	jwks     []byte
+	requests atomic.Int32
+	url      string
+}
+
+func newJWKSServer(t *testing.T, jwks []byte) *jwksServer {
+	s := &jwksServer{jwks: jwks}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(s.jwks)
+	}))
+	t.Cleanup(srv.Close)
+	s.url = srv.URL
+	return s
+}
+
+func (s *jwksServer) serve(jwks []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwks = jwks
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example.com",
+		"aud":   []string{"api", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write",
+	}
+}
+
+func TestJWTAuthenticationFunc(t *testing.T) {
+	signers := []testSigner{
+		newTestSigner(t, "rsa", JWTAlgorithmRS256),
+		newTestSigner(t, "ec", JWTAlgorithmES256),
+		newTestSigner(t, "ed", JWTAlgorithmEdDSA),
+	}
+	jwks := newJWKSServer(t, jwksJSON(t, signers...))
+	opts := JWTOptions{
+		Issuer:   "https://issuer.example.com",
+		Audience: []string{"api"},
+		JWKSURL:  jwks.url,
+	}
+	authFunc, err := JWTAuthenticationFunc(opts)
+	require.NoError(t, err)
+
+	t.Run("verifies the tokens signed by the JWKS keys", func(t *testing.T) {
+		for _, s := range signers {
+			user, err := authFunc(bearerRequest(s.sign(t, validClaims())))
+			require.NoError(t, err, s.alg)
+			assert.Equal(t, "user-1", user.UserID())
+			assert.Equal(t, "read write", user.UserData()["scope"])
+		}
+		assert.EqualValues(t, 1, jwks.requests.Load())
+	})
+
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		claims := func(k string, v any) map[string]any {
+			c := validClaims()
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+			return c
+		}
+		rsaSigner := signers[0]
+		forged := newTestSigner(t, "rsa", JWTAlgorithmRS256)
+		tests := map[string]string{
+			"expired":           rsaSigner.sign(t, claims("exp", time.Now().Add(-2*time.Minute).Unix())),
+			"without exp":       rsaSigner.sign(t, claims("exp", nil)),
+			"not yet valid":     rsaSigner.sign(t, claims("nbf", time.Now().Add(2*time.Minute).Unix())),
+			"of another issuer": rsaSigner.sign(t, claims("iss", "https://evil.example.com")),
+			"of another aud":    rsaSigner.sign(t, claims("aud", "other")),
+			"without subject":   rsaSigner.sign(t, claims("sub", nil)),
+			"forged":            forged.sign(t, validClaims()),
+			"HS256 with the public key": testSigner{kid: "rsa", alg: JWTAlgorithmHS256,
+				key: rsaSigner.key.(*rsa.PrivateKey).N.Bytes()}.sign(t, validClaims()),
+			"unsigned":  testSigner{kid: "rsa", alg: "none", key: []byte{}}.sign(t, validClaims()),
+			"malformed": "not-a-jwt",
+		}
+		for name, token := range tests {
+			_, err := authFunc(bearerRequest(token))
+			assert.ErrorIs(t, err, ErrNotAuthorized, name)
+		}
+
+		_, err := authFunc(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("tolerates the clock skew", func(t *testing.T) {
+		c := validClaims()
+		c["exp"] = time.Now().Add(-30 * time.Second).Unix()
+		c["nbf"] = time.Now().Add(30 * time.Second).Unix()
+		_, err := authFunc(bearerRequest(signers[1].sign(t, c)))
+		assert.NoError(t, err)
+	})
+}
+
+func TestJWTAuthenticationFunc_KeyRotation(t *testing.T) {
+	previous := jwksMinRefetchInterval
+	jwksMinRefetchInterval = 0
+	t.Cleanup(func() { jwksMinRefetchInterval = previous })
+
+	oldKey := newTestSigner(t, "2025", JWTAlgorithmES256)
+	newKey := newTestSigner(t, "2026", JWTAlgorithmES256)
+	jwks := newJWKSServer(t, jwksJSON(t, oldKey))
+	authFunc, err := JWTAuthenticationFunc(JWTOptions{JWKSURL: jwks.url})
+	require.NoError(t, err)
+
+	_, err = authFunc(bearerRequest(oldKey.sign(t, validClaims())))
+	require.NoError(t, err)
+
+	jwks.serve(jwksJSON(t, newKey))
+	_, err = authFunc(bearerRequest(newKey.sign(t, validClaims())))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, jwks.requests.Load())
+}
+
+func TestJWTAuthenticationFunc_FailedFirstFetch(t *testing.T) {
+	signer := newTestSigner(t, "ed", JWTAlgorithmEdDSA)
+	jwks := newJWKSServer(t, []byte("{"))
+	authFunc, err := JWTAuthenticationFunc(JWTOptions{JWKSURL: jwks.url})
+	require.NoError(t, err)
+
+	token := signer.sign(t, validClaims())
+	for range 3 {
+		_, err = authFunc(bearerRequest(token))
+		assert.Error(t, err)
+	}
+	assert.EqualValues(t, 1, jwks.requests.Load(), "the fetches should be limited until the first one succeeds")
+
+	previous := jwksMinRefetchInterval
+	jwksMinRefetchInterval = 0
+	t.Cleanup(func() { jwksMinRefetchInterval = previous })
+	jwks.serve(jwksJSON(t, signer))
+	_, err = authFunc(bearerRequest(token))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, jwks.requests.Load())
+}
+
+func TestJWTAuthenticationFunc_CanceledFirstFetch(t *testing.T) {
+	signer := newTestSigner(t, "ed", JWTAlgorithmEdDSA)
+	jwks := newJWKSServer(t, jwksJSON(t, signer))
+	authFunc, err := JWTAuthenticationFunc(JWTOptions{JWKSURL: jwks.url})
+	require.NoError(t, err)
+
+	token := signer.sign(t, validClaims())
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, _ = authFunc(bearerRequest(token).WithContext(ctx))
+
+	// the canceled request does not fail the next ones
+	_, err = authFunc(bearerRequest(token))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, jwks.requests.Load())
+}
+
+func TestJWTAuthenticationFunc_BackgroundRefresh(t *testing.T) {
+	signer := newTestSigner(t, "ed", JWTAlgorithmEdDSA)
+	jwks := newJWKSServer(t, jwksJSON(t, signer))
+	authFunc, err := JWTAuthenticationFunc(JWTOptions{JWKSURL: jwks.url, RefreshInterval: time.Millisecond})
+	require.NoError(t, err)
+
+	token := signer.sign(t, validClaims())
+	_, err = authFunc(bearerRequest(token))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := authFunc(bearerRequest(token))
+		return err == nil && jwks.requests.Load() >= 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestJWTAuthenticationFunc_StaticKeys(t *testing.T) {
+	t.Run("loads a JWKS file", func(t *testing.T) {
+		signer := newTestSigner(t, "file", JWTAlgorithmRS256)
+		file := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, os.WriteFile(file, jwksJSON(t, signer), 0o600))
+
+		authFunc, err := JWTAuthenticationFunc(JWTOptions{JWKSFile: file})
+		require.NoError(t, err)
+		user, err := authFunc(bearerRequest(signer.sign(t, validClaims())))
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", user.UserID())
+	})
+
+	t.Run("uses the HS256 secret from the config", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthJWTSecretKey, "s3cr3t")
+		viper.Set(configs.HTTPServerAuthJWTUserIDClaimKey, "email")
+		authFunc, err := JWTAuthenticationFunc(JWTOptionsFromConfig())
+		require.NoError(t, err)
+
+		claims := validClaims()
+		claims["email"] = "user@example.com"
+		user, err := authFunc(bearerRequest(testSigner{alg: JWTAlgorithmHS256, key: []byte("s3cr3t")}.sign(t, claims)))
+		require.NoError(t, err)
+		assert.Equal(t, "user@example.com", user.UserID())
+
+		_, err = authFunc(bearerRequest(testSigner{alg: JWTAlgorithmHS256, key: []byte("guess")}.sign(t, claims)))
+		assert.ErrorIs(t, err, ErrNotAuthorized)
+	})
+
+	t.Run("requires a key source", func(t *testing.T) {
+		_, err := JWTAuthenticationFunc(JWTOptions{})
+		assert.ErrorIs(t, err, ErrInvalidJWTConfig)
+		_, err = JWTAuthenticationFunc(JWTOptions{Secret: []byte("s"), Algorithms: []string{"none"}})
+		assert.ErrorIs(t, err, ErrInvalidJWTConfig)
+	})
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+const (
+	// DefaultAuthLimiterMaxFailures is the number of failures blocking a
+	// client IP or credential when not configured.
+	DefaultAuthLimiterMaxFailures = 5
+	// DefaultAuthLimiterWindow is the window counting the failures when not
+	// configured.
+	DefaultAuthLimiterWindow = time.Minute
+	// DefaultAuthLimiterBlockDuration is how long a client IP or credential
+	// stays blocked when not configured.
+	DefaultAuthLimiterBlockDuration = 5 * time.Minute
+	// DefaultAuthLimiterMaxEntries is the number of client IPs and
+	// credentials tracked when not configured.
+	DefaultAuthLimiterMaxEntries = 100_000
+)
+
+// ErrInvalidAuthLimiterConfig is returned when the authentication failure
+// limiter configuration is invalid.
+var ErrInvalidAuthLimiterConfig = errors.New("invalid authentication failure limiter configuration")
+
+// AuthFailureLimiterConfig is the authentication failure limiter
+// configuration (see the `http.server.auth.limiter.*` config keys).
+type AuthFailureLimiterConfig = configs.AuthLimiter
+
+// AuthFailureLimiter blocks the client IPs and the credentials (like an API
+// key or a username) failing to authenticate MaxFailures times within Window,
+// for BlockDuration. At most MaxEntries client IPs and credentials are
+// tracked, the least recently failing ones being forgotten beyond. A nil
+// limiter blocks nothing.
+type AuthFailureLimiter struct {
+	maxFailures    int
+	window         time.Duration
+	blockDuration  time.Duration
+	maxEntries     int
+	trustedProxies []netip.Prefix
+	now            func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*authFailures
+	// order holds the keys of failures, the least recently failing first.
+	order   *list.List
+	sweptAt time.Time
+}
+
+type authFailures struct {
+	count        int
+	since        time.Time
+	blockedUntil time.Time
+	elem         *list.Element
+}
+
+// AuthFailureLimiterFromConfig creates the limiter defined by the
+// `http.server.auth.limiter.*` config keys, nil when it is not enabled.
+func AuthFailureLimiterFromConfig() (*AuthFailureLimiter, error) {
+	cfg := configs.GetAuthLimiter()
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	return NewAuthFailureLimiter(cfg)
+}
+
+// NewAuthFailureLimiter creates a limiter with the thresholds of cfg. Behind
+// the TrustedProxies of cfg, the client IP is the forwarded one, like with
+// the RateLimitMiddleware.
+func NewAuthFailureLimiter(cfg AuthFailureLimiterConfig) (*AuthFailureLimiter, error) {
+	l := &AuthFailureLimiter{
+		maxFailures:   orDefault(cfg.MaxFailures, DefaultAuthLimiterMaxFailures),
+		window:        orDefault(cfg.Window, DefaultAuthLimiterWindow),
+		blockDuration: orDefault(cfg.BlockDuration, DefaultAuthLimiterBlockDuration),
+		maxEntries:    orDefault(cfg.MaxEntries, DefaultAuthLimiterMaxEntries),
+		now:           time.Now,
+		failures:      make(map[string]*authFailures),
+		order:         list.New(),
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: trusted proxy %q: %w", ErrInvalidAuthLimiterConfig, proxy, err)
+		}
+		l.trustedProxies = append(l.trustedProxies, prefix)
+	}
+	return l, nil
+}
+
+// blocked returns how long the most blocked of keys stays blocked, zero when
+// none is.
+func (l *AuthFailureLimiter) blocked(keys ...string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		if f, ok := l.failures[key]; ok {
+			retryAfter = max(retryAfter, f.blockedUntil.Sub(now))
+		}
+	}
+	return retryAfter
+}
+
+// fail counts a failure of keys, blocking those reaching the maximum.
+func (l *AuthFailureLimiter) fail(keys ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok {
+			if len(l.failures) >= l.maxEntries {
+				oldest := l.order.Front()
+				l.remove(oldest.Value.(string))
+			}
+			f = &authFailures{since: now, elem: l.order.PushBack(key)}
+			l.failures[key] = f
+		} else {
+			l.order.MoveToBack(f.elem)
+			if now.Sub(f.since) >= l.window {
+				f.count, f.since = 0, now
+			}
+		}
+		f.count++
+		if f.count >= l.maxFailures {
+			f.blockedUntil = now.Add(l.blockDuration)
+			f.count = 0
+			f.since = now
+		}
+	}
+}
+
+// succeed forgets the failures of keys.
+func (l *AuthFailureLimiter) succeed(keys ...string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.remove(key)
+	}
+}
+
+func (l *AuthFailureLimiter) remove(key string) {
+	if f, ok := l.failures[key]; ok {
+		l.order.Remove(f.elem)
+		delete(l.failures, key)
+	}
+}
+
+// sweep removes the expired entries, at most once per window.
+func (l *AuthFailureLimiter) sweep(now time.Time) {
+	if now.Sub(l.sweptAt) < l.window {
+		return
+	}
+	l.sweptAt = now
+	for key, f := range l.failures {
+		if now.Sub(f.since) >= l.window && !now.Before(f.blockedUntil) {
+			l.remove(key)
+		}
+	}
+}
+
+// limiterKeys returns the limiter keys of the client IP and, when known, of
+// the credential of the request. The Basic usernames are not secret, so they
+// are keyed by client IP too, for anyone not to lock a known user out.
+func limiterKeys(ip string, method *authMethod) []string {
+	keys := []string{"ip:" + ip}
+	if method.credential != "" {
+		key := "credential:" + method.name + ":" + method.credential
+		if method.name == AuthMethodBasic {
+			key += ":" + ip
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// clientIP returns the IP address of the client of the request, forwarded by
+// the trusted proxies of the limiter.
+func (l *AuthFailureLimiter) clientIP(r *http.Request) string {
+	if l == nil {
+		return clientIP(r)
+	}
+	return forwardedClientIP(r, l.trustedProxies)
+}
+
+// clientIP returns the IP address of the client connection.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// credentialFingerprint identifies a secret credential, like an API key,
+// without revealing it.
+func credentialFingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(sum[:defaultLookupTokenSize])
+}
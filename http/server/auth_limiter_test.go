@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// fakeClock is the clock of an AuthFailureLimiter under test.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(t *testing.T, cfg AuthFailureLimiterConfig) (*AuthFailureLimiter, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	limiter, err := NewAuthFailureLimiter(cfg)
+	require.NoError(t, err)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestAuthFailureLimiter(t *testing.T) {
+	t.Run("blocks the keys reaching the maximum failures", func(t *testing.T) {
+		limiter, clock := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 3, Window: time.Minute, BlockDuration: 5 * time.Minute})
+		limiter.fail("ip:10.0.0.1")
+		limiter.fail("ip:10.0.0.1")
+		assert.Zero(t, limiter.blocked("ip:10.0.0.1"))
+
+		limiter.fail("ip:10.0.0.1")
+		assert.Equal(t, 5*time.Minute, limiter.blocked("ip:10.0.0.1", "ip:10.0.0.2"))
+		assert.Zero(t, limiter.blocked("ip:10.0.0.2"))
+
+		clock.now = clock.now.Add(5 * time.Minute)
+		assert.Zero(t, limiter.blocked("ip:10.0.0.1"))
+	})
+
+	t.Run("counts the failures within the window", func(t *testing.T) {
+		limiter, clock := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2, Window: time.Minute})
+		limiter.fail("ip:10.0.0.1")
+		clock.now = clock.now.Add(time.Minute)
+		limiter.fail("ip:10.0.0.1")
+		assert.Zero(t, limiter.blocked("ip:10.0.0.1"))
+		assert.Len(t, limiter.failures, 1)
+	})
+
+	t.Run("forgets the failures on success", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2})
+		limiter.fail("credential:basic:alice")
+		limiter.succeed("credential:basic:alice")
+		limiter.fail("credential:basic:alice")
+		assert.Zero(t, limiter.blocked("credential:basic:alice"))
+	})
+
+	t.Run("forgets the least recently failing keys beyond the maximum entries", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2, MaxEntries: 2})
+		limiter.fail("ip:10.0.0.1")
+		limiter.fail("credential:basic:alice")
+		limiter.fail("ip:10.0.0.1")
+		assert.Positive(t, limiter.blocked("ip:10.0.0.1"))
+
+		limiter.fail("credential:basic:bob")
+		assert.Len(t, limiter.failures, 2)
+		assert.NotContains(t, limiter.failures, "credential:basic:alice")
+		assert.Positive(t, limiter.blocked("ip:10.0.0.1"))
+	})
+
+	t.Run("is disabled by default", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		limiter, err := AuthFailureLimiterFromConfig()
+		require.NoError(t, err)
+		assert.Nil(t, limiter)
+		assert.Zero(t, (*AuthFailureLimiter)(nil).blocked("ip:10.0.0.1"))
+
+		viper.Set(configs.HTTPServerAuthLimiterEnabledKey, true)
+		viper.Set(configs.HTTPServerAuthLimiterMaxFailuresKey, 10)
+		limiter, err = AuthFailureLimiterFromConfig()
+		require.NoError(t, err)
+		require.NotNil(t, limiter)
+		assert.Equal(t, 10, limiter.maxFailures)
+		assert.Equal(t, DefaultAuthLimiterBlockDuration, limiter.blockDuration)
+		assert.Equal(t, DefaultAuthLimiterMaxEntries, limiter.maxEntries)
+
+		viper.Set(configs.HTTPServerAuthLimiterTrustedProxiesKey, []string{"10.0.0.0/33"})
+		_, err = AuthFailureLimiterFromConfig()
+		assert.ErrorIs(t, err, ErrInvalidAuthLimiterConfig)
+	})
+}
+
+func TestAuthenticationMiddleware_Telemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	var logs lockedBuffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	handler := AuthenticationMiddleware(
+		SingleUserApiKeyAuthenticationFunc("my-key", "", testUser{id: "u1"}),
+		WithAuthMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)(okHandler())
+
+	for _, key := range []string{"my-key", "my-key", "wrong-key", ""} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, apiKeyRequest(key))
+		if key == "wrong-key" {
+			assert.Equal(t, "unauthorized\n", w.Body.String())
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[attribute.Set]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "http.server.auth.attempts" {
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					counts[dp.Attributes] = dp.Value
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[attribute.Set]int64{
+		attribute.NewSet(attribute.String("auth.method", "api_key"), attribute.String("auth.result", "success")):     2,
+		attribute.NewSet(attribute.String("auth.method", "api_key"), attribute.String("auth.result", "failure")):     1,
+		attribute.NewSet(attribute.String("auth.method", "none"), attribute.String("auth.result", "no_credentials")): 1,
+	}, counts)
+
+	assert.Contains(t, logs.String(), `"msg":"authentication failed","auth_method":"api_key","client_ip":"192.0.2.1","header":"X-Api-Key"`)
+	assert.NotContains(t, logs.String(), "wrong-key")
+
+	t.Run("records the result on the span", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, span := tp.Tracer("test").Start(t.Context(), "request")
+		handler.ServeHTTP(httptest.NewRecorder(), apiKeyRequest("wrong-key").WithContext(ctx))
+		span.End()
+
+		require.Len(t, recorder.Ended(), 1)
+		attrs := recorder.Ended()[0].Attributes()
+		assert.Contains(t, attrs, attribute.String("auth.method", "api_key"))
+		assert.Contains(t, attrs, attribute.String("auth.result", "failure"))
+	})
+}
+
+func TestAuthenticationMiddleware_Limiter(t *testing.T) {
+	t.Run("throttles the client IP", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2, BlockDuration: 90 * time.Second})
+		calls := 0
+		authFunc := func(r *http.Request) (User, error) {
+			calls++
+			return SingleUserApiKeyAuthenticationFunc("my-key", "", testUser{id: "u1"})(r)
+		}
+		handler := AuthenticationMiddleware(authFunc, WithAuthFailureLimiter(limiter))(okHandler())
+
+		for _, key := range []string{"guess-1", "guess-2"} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, apiKeyRequest(key))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, apiKeyRequest("my-key"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "90", w.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusTooManyRequests, decodeProblem(t, w).Status)
+		assert.Equal(t, 2, calls)
+
+		// other clients are not throttled
+		req := apiKeyRequest("my-key")
+		req.RemoteAddr = "192.0.2.2:1234"
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("throttles the client IP forwarded by the trusted proxies", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 1, TrustedProxies: []string{"10.0.0.0/8"}})
+		handler := AuthenticationMiddleware(tokenAuthFunc, WithAuthFailureLimiter(limiter))(okHandler())
+		serve := func(clientIP, token string) int {
+			req := authRequest(map[string]string{"X-Token": token, "X-Forwarded-For": clientIP})
+			req.RemoteAddr = "10.0.0.1:1234"
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, serve("203.0.113.5", "guess"))
+		assert.Equal(t, http.StatusTooManyRequests, serve("203.0.113.5", "valid"))
+		// the other clients behind the proxy are not throttled
+		assert.Equal(t, http.StatusOK, serve("203.0.113.6", "valid"))
+	})
+
+	t.Run("throttles the credentials", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2})
+		authFunc := SingleUserApiKeyAuthenticationFunc("my-key", "", testUser{id: "u1"})
+		handler := AuthenticationMiddleware(authFunc, WithAuthFailureLimiter(limiter))(okHandler())
+
+		for i, wantCode := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+			req := apiKeyRequest("guess")
+			req.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", i+1)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, wantCode, w.Code)
+		}
+	})
+
+	t.Run("throttles the Basic users by client IP", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), ".htpasswd")
+		require.NoError(t, os.WriteFile(file, []byte(htpasswdLine(t, "alice", "s3cret", false)), 0o600))
+		authFunc, err := NewBasicAuthenticationFunc(BasicAuthConfig{HtpasswdFile: file})
+		require.NoError(t, err)
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 2})
+		handler := AuthenticationMiddleware(authFunc, WithAuthFailureLimiter(limiter))(okHandler())
+		serve := func(clientIP, password string) int {
+			req := basicRequest("alice", password)
+			req.RemoteAddr = clientIP + ":1234"
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		for i := range 4 {
+			assert.Equal(t, http.StatusUnauthorized, serve(fmt.Sprintf("192.0.2.%d", i+1), "guess"))
+		}
+		// the failures from other clients do not lock the user out
+		assert.Equal(t, http.StatusOK, serve("192.0.2.10", "s3cret"))
+		// but they are throttled from the failing client
+		assert.Equal(t, http.StatusUnauthorized, serve("198.51.100.1", "guess"))
+		assert.Equal(t, http.StatusUnauthorized, serve("198.51.100.1", "guess"))
+		assert.Equal(t, http.StatusTooManyRequests, serve("198.51.100.1", "s3cret"))
+	})
+
+	t.Run("is disabled without a limiter", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAuthLimiterEnabledKey, true)
+		viper.Set(configs.HTTPServerAuthLimiterMaxFailuresKey, 1)
+		handler := AuthenticationMiddleware(tokenAuthFunc)(okHandler())
+
+		handler.ServeHTTP(httptest.NewRecorder(), authRequest(map[string]string{"X-Token": "guess"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, authRequest(map[string]string{"X-Token": "valid"}))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("does not count the requests without credentials", func(t *testing.T) {
+		limiter, _ := newTestLimiter(t, AuthFailureLimiterConfig{MaxFailures: 1})
+		handler := AuthenticationMiddleware(tokenAuthFunc, WithAuthFailureLimiter(limiter))(okHandler())
+
+		handler.ServeHTTP(httptest.NewRecorder(), authRequest(nil))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, authRequest(map[string]string{"X-Token": "valid"}))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
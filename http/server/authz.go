@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrForbidden is returned by the policies denying the access.
+var ErrForbidden = errors.New("forbidden")
+
+// Policy decides whether the authenticated user may perform the request,
+// returning an error explaining why when it may not.
+type Policy func(r *http.Request, user User) error
+
+// RolesUser is a User exposing its roles, otherwise read from the `roles`
+// (or `role`) entry of UserData.
+type RolesUser interface {
+	UserRoles() []string
+}
+
+// ScopesUser is a User exposing its scopes, otherwise read from the `scope`
+// (space separated, like OAuth 2.0 tokens), `scopes` or `scp` entry of UserData.
+type ScopesUser interface {
+	UserScopes() []string
+}
+
+// RoutePermission is the permission required by an http.ServeMux pattern
+// (see the `http.server.authz.routes` config key).
+type RoutePermission = configs.AuthzRoute
+
+// RoutePermissionsFromConfig returns the route-to-permission table defined by
+// the `http.server.authz.routes` config key, or an error if it cannot be
+// decoded.
+func RoutePermissionsFromConfig() ([]RoutePermission, error) {
+	routes, err := configs.GetAuthzRoutes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", configs.HTTPServerAuthzRoutesKey, err)
+	}
+	return routes, nil
+}
+
+// HasAnyRole allows the users having at least one of roles.
+func HasAnyRole(roles ...string) Policy {
+	return func(_ *http.Request, user User) error {
+		userRoles := claimValues(user, "roles", "role")
+		if r, ok := user.(RolesUser); ok {
+			userRoles = r.UserRoles()
+		}
+		for _, role := range roles {
+			if slices.Contains(userRoles, role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: one of the roles %s is required", ErrForbidden, strings.Join(roles, ", "))
+	}
+}
+
+// HasScopes allows the users having all the scopes.
+func HasScopes(scopes ...string) Policy {
+	return func(_ *http.Request, user User) error {
+		userScopes := claimValues(user, "scope", "scopes", "scp")
+		if s, ok := user.(ScopesUser); ok {
+			userScopes = s.UserScopes()
+		}
+		var missing []string
+		for _, scope := range scopes {
+			if !slices.Contains(userScopes, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: missing the scopes %s", ErrForbidden, strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+// claimValues returns the values of the first UserData entry found, a
+// space separated string or a list of strings.
+func claimValues(user User, keys ...string) []string {
+	data := user.UserData()
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case string:
+			return strings.Fields(v)
+		case []string:
+			return v
+		case []any:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+			return values
+		}
+	}
+	return nil
+}
+
+// RequireRoles is a middleware allowing the authenticated users having at
+// least one of roles (see RequirePolicy).
+func RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return RequirePolicy(HasAnyRole(roles...))
+}
+
+// RequireScopes is a middleware allowing the authenticated users having all
+// the scopes (see RequirePolicy).
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return RequirePolicy(HasScopes(scopes...))
+}
+
+// RequirePolicy is a middleware allowing the user authenticated by the
+// AuthenticationMiddleware when all the policies allow it. Denied requests get
+// a 403 problem details (RFC 9457) response, 401 without an authenticated user.
+// The decision is logged and recorded as the `authz.decision` attribute of the
+// span.
+func RequirePolicy(policies ...Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authorize(w, r, policies) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// AuthorizeRoutes is a middleware applying the permissions of the routes to
+// the requests matching their patterns, other requests being allowed. It reads
+// the pattern of the request routed by an http.ServeMux, so it wraps either
+// the handlers of the mux or the mux itself. Requests whose pattern cannot be
+// resolved, like when the mux is wrapped by another middleware, are denied with
+// a 500 problem details response.
+func AuthorizeRoutes(routes []RoutePermission) func(http.Handler) http.Handler {
+	policies := make(map[string][]Policy, len(routes))
+	for _, route := range routes {
+		var ps []Policy
+		if len(route.Roles) > 0 {
+			ps = append(ps, HasAnyRole(route.Roles...))
+		}
+		if len(route.Scopes) > 0 {
+			ps = append(ps, HasScopes(route.Scopes...))
+		}
+		policies[route.Pattern] = ps
+	}
+	return func(next http.Handler) http.Handler {
+		mux, _ := next.(*http.ServeMux)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Pattern == "" && mux == nil {
+				trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("authz.decision", "deny"))
+				slog.ErrorContext(r.Context(), "authorization denied", "method", r.Method, "path", r.URL.Path, "reason", "route pattern not resolved, AuthorizeRoutes must wrap an http.ServeMux or its handlers")
+				writeProblem(w, r, http.StatusInternalServerError, "route cannot be authorized")
+				return
+			}
+			if ps, ok := policies[routePattern(r, mux)]; ok && !authorize(w, r, ps) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func authorize(w http.ResponseWriter, r *http.Request, policies []Policy) bool {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	user := AuthenticatedUserFromContext(ctx)
+	if user == nil {
+		span.SetAttributes(attribute.String("authz.decision", "deny"))
+		slog.InfoContext(ctx, "authorization denied", "method", r.Method, "path", r.URL.Path, "reason", "not authenticated")
+		writeProblem(w, r, http.StatusUnauthorized, "authentication required")
+		return false
+	}
+	for _, policy := range policies {
+		if err := policy(r, user); err != nil {
+			span.SetAttributes(attribute.String("authz.decision", "deny"), attribute.String("authz.reason", err.Error()))
+			slog.InfoContext(ctx, "authorization denied", "user_id", user.UserID(), "method", r.Method, "path", r.URL.Path, "reason", err.Error())
+			writeProblem(w, r, http.StatusForbidden, err.Error())
+			return false
+		}
+	}
+	span.SetAttributes(attribute.String("authz.decision", "allow"))
+	slog.DebugContext(ctx, "authorization granted", "user_id", user.UserID(), "method", r.Method, "path", r.URL.Path)
+	return true
+}
+
+// problemDetails is an RFC 9457 problem details body.
+type problemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
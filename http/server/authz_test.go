@@ -0,0 +1,223 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+type claimsUser struct {
+	id     string
+	claims map[string]any
+}
+
+func (u claimsUser) UserID() string {
+	return u.id
+}
+
+func (u claimsUser) UserData() map[string]any {
+	return u.claims
+}
+
+type rolesUser struct {
+	testUser
+	roles []string
+}
+
+func (u rolesUser) UserRoles() []string {
+	return u.roles
+}
+
+// asUser authenticates the requests as user.
+func asUser(user User) func(http.Handler) http.Handler {
+	return AuthenticationMiddleware(func(*http.Request) (User, error) {
+		return user, nil
+	})
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problemDetails {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var problem problemDetails
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	return problem
+}
+
+func TestRequireRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     User
+		wantCode int
+	}{
+		{name: "user data roles", user: claimsUser{id: "u1", claims: map[string]any{"roles": []any{"viewer", "admin"}}}, wantCode: http.StatusOK},
+		{name: "RolesUser roles", user: rolesUser{testUser: testUser{id: "u2"}, roles: []string{"editor"}}, wantCode: http.StatusOK},
+		{name: "other roles", user: claimsUser{id: "u3", claims: map[string]any{"roles": []string{"viewer"}}}, wantCode: http.StatusForbidden},
+		{name: "no roles", user: testUser{id: "u4"}, wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			asUser(tt.user)(RequireRoles("admin", "editor")(okHandler())).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+
+	t.Run("returns problem details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		asUser(testUser{id: "u4"})(RequireRoles("admin")(okHandler())).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		assert.Equal(t, problemDetails{
+			Type:     "about:blank",
+			Title:    "Forbidden",
+			Status:   http.StatusForbidden,
+			Detail:   "forbidden: one of the roles admin is required",
+			Instance: "/orders",
+		}, decodeProblem(t, w))
+	})
+
+	t.Run("requires an authenticated user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		RequireRoles("admin")(okHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, http.StatusUnauthorized, decodeProblem(t, w).Status)
+	})
+}
+
+func TestRequireScopes(t *testing.T) {
+	handler := RequireScopes("orders:read", "orders:write")(okHandler())
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		wantCode int
+	}{
+		{name: "OAuth scope string", claims: map[string]any{"scope": "orders:read orders:write profile"}, wantCode: http.StatusOK},
+		{name: "scp list", claims: map[string]any{"scp": []any{"orders:write", "orders:read"}}, wantCode: http.StatusOK},
+		{name: "missing scope", claims: map[string]any{"scope": "orders:read"}, wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			asUser(claimsUser{id: "u1", claims: tt.claims})(handler).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+
+	t.Run("records the decision on the span", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, span := tp.Tracer("test").Start(t.Context(), "request")
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil).WithContext(ctx)
+		asUser(claimsUser{id: "u1", claims: map[string]any{"scope": "orders:read"}})(handler).ServeHTTP(httptest.NewRecorder(), req)
+		span.End()
+
+		require.Len(t, recorder.Ended(), 1)
+		attrs := recorder.Ended()[0].Attributes()
+		assert.Contains(t, attrs, attribute.String("authz.decision", "deny"))
+		assert.Contains(t, attrs, attribute.String("authz.reason", "forbidden: missing the scopes orders:write"))
+	})
+}
+
+func TestRequirePolicy(t *testing.T) {
+	ownOrders := func(r *http.Request, user User) error {
+		if r.PathValue("user") != user.UserID() {
+			return ErrForbidden
+		}
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{user}/orders", RequirePolicy(ownOrders)(okHandler()))
+	handler := asUser(testUser{id: "alice"})(mux)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/alice/orders", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/bob/orders", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAuthorizeRoutes(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(configs.HTTPServerAuthzRoutesKey, []map[string]any{
+		{"pattern": "DELETE /orders/{id}", "roles": []string{"admin"}},
+		{"pattern": "GET /orders", "scopes": []string{"orders:read"}},
+	})
+	routes, err := RoutePermissionsFromConfig()
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /orders", okHandler())
+	mux.Handle("DELETE /orders/{id}", okHandler())
+	mux.Handle("GET /health", okHandler())
+	handler := asUser(claimsUser{id: "u1", claims: map[string]any{"scope": "orders:read", "roles": "viewer"}})(AuthorizeRoutes(routes)(mux))
+
+	for req, wantCode := range map[*http.Request]int{
+		httptest.NewRequest(http.MethodGet, "/orders", nil):       http.StatusOK,
+		httptest.NewRequest(http.MethodDelete, "/orders/42", nil): http.StatusForbidden,
+		httptest.NewRequest(http.MethodGet, "/health", nil):       http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, wantCode, w.Code, req.Method+" "+req.URL.Path)
+	}
+
+	t.Run("wraps the handlers of the mux", func(t *testing.T) {
+		mux := http.NewServeMux()
+		authz := AuthorizeRoutes(routes)
+		mux.Handle("DELETE /orders/{id}", authz(okHandler()))
+		mux.Handle("GET /health", authz(okHandler()))
+		handler := asUser(claimsUser{id: "u1", claims: map[string]any{"roles": "viewer"}})(mux)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/orders/42", nil))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("denies the requests when the mux is wrapped by another middleware", func(t *testing.T) {
+		wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mux.ServeHTTP(w, r)
+		})
+		handler := asUser(claimsUser{id: "u1", claims: map[string]any{"roles": "admin"}})(AuthorizeRoutes(routes)(wrapped))
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodDelete, "/orders/42", nil),
+			httptest.NewRequest(http.MethodGet, "/health", nil),
+		} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusInternalServerError, w.Code, req.Method+" "+req.URL.Path)
+			assert.Equal(t, "route cannot be authorized", decodeProblem(t, w).Detail)
+		}
+	})
+}
+
+func TestRoutePermissionsFromConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(configs.HTTPServerAuthzRoutesKey, []map[string]any{
+		{"pattern": "DELETE /orders/{id}", "roles": map[string]any{"admin": true}},
+	})
+	routes, err := RoutePermissionsFromConfig()
+	assert.Error(t, err)
+	assert.Nil(t, routes)
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is how often the JWKS URL keys are refreshed
+	// when not configured.
+	DefaultJWKSRefreshInterval = 15 * time.Minute
+
+	maxJWKSSize = 1 << 20
+	minRSABits  = 2048
+)
+
+// jwksMinRefetchInterval limits the fetches triggered by tokens signed by an
+// unknown key.
+var jwksMinRefetchInterval = 30 * time.Second
+
+// jwtKey is a verification key of a JWKS.
+type jwtKey struct {
+	kid string
+	alg string
+	// key is a *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or a []byte
+	// HMAC secret.
+	key any
+}
+
+// keySet returns the verification keys, the key ID helping the remote ones
+// to detect a rotation.
+type keySet interface {
+	keys(ctx context.Context, kid string) ([]jwtKey, error)
+}
+
+type staticKeySet []jwtKey
+
+func (s staticKeySet) keys(context.Context, string) ([]jwtKey, error) {
+	return s, nil
+}
+
+func loadJWKSFile(path string) (staticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JWKS file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// remoteKeySet caches the keys of a JWKS URL. They are fetched on first use,
+// refreshed in the background once older than the interval, and fetched
+// again when a token is signed by an unknown key (a rotation). Until the
+// first fetch succeeds, fetches are retried at most once per
+// jwksMinRefetchInterval, the last error being returned meanwhile.
+type remoteKeySet struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+
+	// fetchMu serializes the fetches
+	fetchMu sync.Mutex
+
+	mu         sync.Mutex
+	cached     []jwtKey
+	checkedAt  time.Time
+	lastErr    error
+	refreshing bool
+}
+
+func (s *remoteKeySet) keys(ctx context.Context, kid string) ([]jwtKey, error) {
+	s.mu.Lock()
+	keys, checkedAt, lastErr := s.cached, s.checkedAt, s.lastErr
+	if keys != nil && time.Since(checkedAt) >= s.interval && !s.refreshing {
+		s.refreshing = true
+		go s.backgroundRefresh(context.WithoutCancel(ctx), checkedAt)
+	}
+	s.mu.Unlock()
+
+	if keys != nil && (kid == "" || hasKeyID(keys, kid) || time.Since(checkedAt) < jwksMinRefetchInterval) {
+		return keys, nil
+	}
+	if keys == nil && lastErr != nil && time.Since(checkedAt) < jwksMinRefetchInterval {
+		return nil, lastErr
+	}
+	if err := s.refresh(ctx, checkedAt); err != nil {
+		if keys == nil {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "failed to fetch JWKS, keeping the current keys", "url", s.url, "error", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached == nil {
+		if s.lastErr != nil {
+			return nil, s.lastErr
+		}
+		return nil, fmt.Errorf("no key fetched from %s", s.url)
+	}
+	return s.cached, nil
+}
+
+func (s *remoteKeySet) backgroundRefresh(ctx context.Context, since time.Time) {
+	if err := s.refresh(ctx, since); err != nil {
+		slog.WarnContext(ctx, "failed to refresh JWKS, keeping the current keys", "url", s.url, "error", err)
+	}
+	s.mu.Lock()
+	s.refreshing = false
+	s.mu.Unlock()
+}
+
+// refresh fetches the keys, unless a concurrent call did after since.
+func (s *remoteKeySet) refresh(ctx context.Context, since time.Time) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	s.mu.Lock()
+	fetched := s.checkedAt.After(since)
+	s.mu.Unlock()
+	if fetched {
+		return nil
+	}
+
+	// the fetch outlives the request triggering it, so a client canceling its
+	// request cannot fail the authentication of the others
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultJWKSFetchTimeout)
+	keys, err := s.fetch(fetchCtx)
+	cancel()
+	if err != nil && ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkedAt = time.Now()
+	s.lastErr = err
+	if err != nil {
+		return err
+	}
+	s.cached = keys
+	return nil
+}
+
+func (s *remoteKeySet) fetch(ctx context.Context) ([]jwtKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS: %w", err)
+	}
+	return parseJWKS(data)
+}
+
+func hasKeyID(keys []jwtKey, kid string) bool {
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// jwk is a JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS returns the signature keys of the JWKS, skipping the encryption
+// and unsupported ones.
+func parseJWKS(data []byte) ([]jwtKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+	keys := make([]jwtKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("skipping invalid JWK", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys = append(keys, jwtKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKField(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKField(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key smaller than %d bits", minRSABits)
+		}
+		return pub, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKField(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKField(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("invalid P-256 coordinates")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKField(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decodeJWKField(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeJWKField(v string) ([]byte, error) {
+	if v == "" {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("decoding key parameter: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/logs"
+)
+
+// LogLevels is the body of the log level admin endpoint responses.
+type LogLevels struct {
+	Level  string            `json:"level"`
+	Levels map[string]string `json:"levels"`
+	// Expires holds when the temporary levels revert, indexed by logger name
+	// (the default level uses an empty name).
+	Expires map[string]time.Time `json:"expires,omitempty"`
+}
+
+// LogLevelChange is the body of the log level admin endpoint PUT requests.
+type LogLevelChange struct {
+	// Logger is the name of the logger to change, the default level is
+	// changed when empty.
+	Logger string `json:"logger,omitempty"`
+	Level  string `json:"level"`
+	// Duration makes the change temporary (e.g. `10m`), reverting it once elapsed.
+	Duration string `json:"duration,omitempty"`
+}
+
+type logLevelHandler struct {
+	router *logs.LevelRouter
+}
+
+// LogLevelHandler returns the log level admin endpoint, protected by the
+// AuthenticationMiddleware using authFunc. It changes the levels of router
+// (the default logs.LevelRouter when nil):
+//   - GET returns the current levels;
+//   - PUT changes a level, described by a LogLevelChange body;
+//   - DELETE removes the override of the logger given by the `logger` query parameter.
+func LogLevelHandler(authFunc UserAuthenticationFunc, router *logs.LevelRouter) http.Handler {
+	if router == nil {
+		router = logs.DefaultLevelRouter()
+	}
+	if authFunc == nil {
+		authFunc = func(_ *http.Request) (User, error) {
+			return nil, ErrNotAuthorized
+		}
+	}
+	return AuthenticationMiddleware(authFunc)(&logLevelHandler{router: router})
+}
+
+func (h *logLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := h.change(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		logger := r.URL.Query().Get("logger")
+		if logger == "" {
+			http.Error(w, "missing logger query parameter", http.StatusBadRequest)
+			return
+		}
+		h.router.ResetLevel(logger)
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.levels())
+}
+
+func (h *logLevelHandler) change(r *http.Request) error {
+	var change LogLevelChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(change.Level)); err != nil {
+		return fmt.Errorf("invalid level: %w", err)
+	}
+
+	if change.Duration == "" {
+		if change.Logger == "" {
+			h.router.SetDefaultLevel(level)
+		} else {
+			h.router.SetLevel(change.Logger, level)
+		}
+		return nil
+	}
+
+	d, err := time.ParseDuration(change.Duration)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid duration: %q", change.Duration)
+	}
+	if change.Logger == "" {
+		h.router.SetDefaultLevelFor(level, d)
+	} else {
+		h.router.SetLevelFor(change.Logger, level, d)
+	}
+	return nil
+}
+
+func (h *logLevelHandler) levels() LogLevels {
+	overrides := h.router.Levels()
+	levels := LogLevels{
+		Level:   logs.LevelName(h.router.DefaultLevel()),
+		Levels:  make(map[string]string, len(overrides)),
+		Expires: h.router.Expirations(),
+	}
+	for name, level := range overrides {
+		levels.Levels[name] = logs.LevelName(level)
+	}
+	return levels
+}
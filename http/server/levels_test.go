@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eldius/initial-config-go/logs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	const apiKey = "admin-key"
+	newHandler := func() (http.Handler, *logs.LevelRouter) {
+		router := logs.NewLevelRouter(slog.LevelInfo, map[string]slog.Level{"http_client": slog.LevelDebug})
+		return LogLevelHandler(SingleUserApiKeyAuthenticationFunc(apiKey, "", testUser{id: "admin"}), router), router
+	}
+	call := func(t *testing.T, h http.Handler, method, target, body string) (*httptest.ResponseRecorder, LogLevels) {
+		t.Helper()
+		req := httptest.NewRequestWithContext(t.Context(), method, target, strings.NewReader(body))
+		req.Header.Set(DefaultXApiKeyHeaderName, apiKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var levels LogLevels
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &levels))
+		}
+		return rec, levels
+	}
+
+	t.Run("returns the current levels", func(t *testing.T) {
+		h, _ := newHandler()
+		rec, levels := call(t, h, http.MethodGet, "/", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "info", levels.Level)
+		assert.Equal(t, map[string]string{"http_client": "debug"}, levels.Levels)
+		assert.Empty(t, levels.Expires)
+	})
+
+	t.Run("changes the default and named levels", func(t *testing.T) {
+		h, router := newHandler()
+		_, _ = call(t, h, http.MethodPut, "/", `{"level":"warn"}`)
+		rec, levels := call(t, h, http.MethodPut, "/", `{"logger":"telemetry","level":"error"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "warn", levels.Level)
+		assert.Equal(t, "error", levels.Levels["telemetry"])
+		assert.Equal(t, slog.LevelError, router.Level("telemetry"))
+	})
+
+	t.Run("changes a level temporarily", func(t *testing.T) {
+		h, router := newHandler()
+		rec, levels := call(t, h, http.MethodPut, "/", `{"logger":"database","level":"debug","duration":"10m"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "debug", levels.Levels["database"])
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), levels.Expires["database"], time.Minute)
+		assert.Equal(t, slog.LevelDebug, router.Level("database"))
+	})
+
+	t.Run("removes an override", func(t *testing.T) {
+		h, _ := newHandler()
+		rec, levels := call(t, h, http.MethodDelete, "/?logger=http_client", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, levels.Levels)
+	})
+
+	t.Run("rejects invalid changes", func(t *testing.T) {
+		h, _ := newHandler()
+		for _, body := range []string{`{"level":"verbose"}`, `{"level":"debug","duration":"forever"}`, `{"level":"debug","duration":"-1m"}`, `not json`} {
+			rec, _ := call(t, h, http.MethodPut, "/", body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		}
+		rec, _ := call(t, h, http.MethodPost, "/", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		h, router := newHandler()
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodPut, "/", strings.NewReader(`{"level":"debug"}`))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, slog.LevelInfo, router.DefaultLevel())
+
+		rec = httptest.NewRecorder()
+		LogLevelHandler(nil, router).ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
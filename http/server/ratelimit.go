@@ -0,0 +1,255 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// DefaultRateLimitPeriod is the period of the limits defining none.
+const DefaultRateLimitPeriod = time.Minute
+
+// ErrInvalidRateLimitConfig is returned when the rate limiting configuration is invalid.
+var ErrInvalidRateLimitConfig = errors.New("invalid rate limit configuration")
+
+// RateLimit is a token bucket limit of Requests per Period, allowing bursts of
+// Burst requests (Requests when zero). A limit without Requests is unlimited.
+type RateLimit = configs.RateLimit
+
+// RouteRateLimit is the limit of an http.ServeMux pattern.
+type RouteRateLimit = configs.RouteRateLimit
+
+// RateLimitOptions configures the RateLimitMiddleware.
+type RateLimitOptions struct {
+	// Default is the limit of the requests without a route or tier limit.
+	Default RateLimit
+	// Tiers are the limits by tier of the authenticated users, read from the
+	// TierKey entry (`tier` by default) of UserData.
+	Tiers   map[string]RateLimit
+	TierKey string
+	// Routes are the limits of the requests matching their patterns,
+	// prevailing over the tiers.
+	Routes []RouteRateLimit
+	// TrustedProxies are the IPs or CIDRs of the proxies whose
+	// `X-Forwarded-For` (or `X-Real-IP`) client IP is used.
+	TrustedProxies []string
+	// Store stores the token buckets (a MemoryRateLimitStore by default).
+	Store RateLimitStore
+
+	// configErr is the error decoding the config, returned by the
+	// RateLimitMiddleware.
+	configErr error
+}
+
+// RateLimitOptionsFromConfig returns the rate limiting options defined by the
+// `http.server.rate_limit.*` config keys. The RateLimitMiddleware returns the
+// error decoding them.
+func RateLimitOptionsFromConfig() RateLimitOptions {
+	cfg, err := configs.GetRateLimit()
+	return RateLimitOptions{
+		Default:        cfg.Default,
+		Tiers:          cfg.Tiers,
+		TierKey:        cfg.TierKey,
+		Routes:         cfg.Routes,
+		TrustedProxies: cfg.TrustedProxies,
+		configErr:      err,
+	}
+}
+
+type rateLimiter struct {
+	opts           RateLimitOptions
+	routes         map[string]RateLimit
+	trustedProxies []netip.Prefix
+}
+
+// RateLimitMiddleware is a middleware limiting the requests with token
+// buckets. The requests of the users authenticated by the
+// AuthenticationMiddleware are limited by API key or by user, the others by
+// client IP. The limit is the one of the route pattern, else the one of the
+// user tier, else the default one.
+//
+// Responses get the `RateLimit-Limit`, `RateLimit-Remaining`, `RateLimit-Reset`
+// and `RateLimit-Policy` headers, and limited requests a 429 problem details
+// response with `Retry-After`. Requests are allowed when the store fails.
+func RateLimitMiddleware(opts RateLimitOptions) (func(http.Handler) http.Handler, error) {
+	if opts.configErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRateLimitConfig, opts.configErr)
+	}
+	l := &rateLimiter{opts: opts, routes: make(map[string]RateLimit, len(opts.Routes))}
+	l.opts.TierKey = orDefault(opts.TierKey, "tier")
+	if l.opts.Store == nil {
+		l.opts.Store = NewMemoryRateLimitStore()
+	}
+	for name, limit := range opts.Tiers {
+		if err := validateRateLimit(limit); err != nil {
+			return nil, fmt.Errorf("%w: tier %q: %w", ErrInvalidRateLimitConfig, name, err)
+		}
+	}
+	for _, route := range opts.Routes {
+		if err := validateRateLimit(route.RateLimit); err != nil {
+			return nil, fmt.Errorf("%w: route %q: %w", ErrInvalidRateLimitConfig, route.Pattern, err)
+		}
+		l.routes[route.Pattern] = route.RateLimit
+	}
+	if err := validateRateLimit(opts.Default); err != nil {
+		return nil, fmt.Errorf("%w: default: %w", ErrInvalidRateLimitConfig, err)
+	}
+	for _, proxy := range opts.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: trusted proxy %q: %w", ErrInvalidRateLimitConfig, proxy, err)
+		}
+		l.trustedProxies = append(l.trustedProxies, prefix)
+	}
+
+	return func(next http.Handler) http.Handler {
+		mux, _ := next.(*http.ServeMux)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, limit := l.limit(r, routePattern(r, mux))
+			if limit.Requests <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limit.Period = orDefault(limit.Period, DefaultRateLimitPeriod)
+			subject := l.subject(r)
+			result, err := l.opts.Store.Take(r.Context(), scope+"|"+subject, limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "rate limit store failed, allowing the request", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Period)))
+			if !result.Allowed {
+				slog.DebugContext(r.Context(), "request rate limited", "subject", subject, "scope", scope, "method", r.Method, "path", r.URL.Path)
+				h.Set("Retry-After", ceilSeconds(result.RetryAfter))
+				writeProblem(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// limit returns the limit of the request and the scope of its buckets.
+func (l *rateLimiter) limit(r *http.Request, pattern string) (string, RateLimit) {
+	if limit, ok := l.routes[pattern]; ok {
+		return "route:" + pattern, limit
+	}
+	if user := AuthenticatedUserFromContext(r.Context()); user != nil {
+		if tier, ok := user.UserData()[l.opts.TierKey].(string); ok {
+			if limit, ok := l.opts.Tiers[tier]; ok {
+				return "tier:" + tier, limit
+			}
+		}
+	}
+	return "default", l.opts.Default
+}
+
+// subject returns the key of the client of the request: its API key, its
+// user or its IP.
+func (l *rateLimiter) subject(r *http.Request) string {
+	ctx := r.Context()
+	if user := AuthenticatedUserFromContext(ctx); user != nil {
+		method, _ := ctx.Value(authMethodKey).(*authMethod)
+		switch {
+		case method != nil && method.name == AuthMethodAnonymous:
+		case method != nil && method.name == AuthMethodAPIKey && method.credential != "":
+			return "api_key:" + method.credential
+		default:
+			return "user:" + user.UserID()
+		}
+	}
+	return "ip:" + forwardedClientIP(r, l.trustedProxies)
+}
+
+func validateRateLimit(limit RateLimit) error {
+	if limit.Period < 0 || limit.Burst < 0 {
+		return errors.New("negative period or burst")
+	}
+	return nil
+}
+
+func rateLimitBurst(limit RateLimit) int {
+	return orDefault(limit.Burst, limit.Requests)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// routePattern returns the pattern of the request routed by an
+// http.ServeMux, either the current one or the one of mux.
+func routePattern(r *http.Request, mux *http.ServeMux) string {
+	if r.Pattern == "" && mux != nil {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	return r.Pattern
+}
+
+// forwardedClientIP returns the client IP of the request. Behind trusted
+// proxies, it is the last address of `X-Forwarded-For` that is not a trusted
+// proxy, or the `X-Real-IP` address.
+func forwardedClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	ip := clientIP(r)
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		addrs := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(addrs[i]))
+			if err != nil {
+				break
+			}
+			ip = addr.Unmap().String()
+			if !isTrustedProxy(ip, trustedProxies) {
+				break
+			}
+		}
+		return ip
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefix parses a CIDR or a single IP.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the state of a token bucket after taking a token.
+type RateLimitResult struct {
+	// Allowed is whether a token was taken.
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of tokens left.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, when not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore stores the token buckets of the RateLimitMiddleware. The
+// MemoryRateLimitStore keeps them in the process; a shared store lets
+// several instances enforce the same limits.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, refilled at the rate of
+	// limit.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is an in-memory RateLimitStore. Full buckets are
+// removed, so idle clients do not use memory.
+type MemoryRateLimitStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	sweptAt time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   float64
+}
+
+const rateLimitSweepInterval = time.Minute
+
+// NewMemoryRateLimitStore creates an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+	burst := float64(rateLimitBurst(limit))
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+	b.rate, b.burst = rate, burst
+	b.tokens = math.Min(b.tokens, burst)
+
+	result := RateLimitResult{Limit: int(burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / rate)
+	return result, nil
+}
+
+// sweep removes the full buckets, at most once per sweep interval.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < rateLimitSweepInterval {
+		return
+	}
+	s.sweptAt = now
+	for key, b := range s.buckets {
+		if b.refill(now); b.tokens >= b.burst {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// refill adds the tokens accumulated since the last update.
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.updated.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	}
+	b.updated = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func newTestRateLimitStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+func newRateLimited(t *testing.T, opts RateLimitOptions, next http.Handler) http.Handler {
+	t.Helper()
+	middleware, err := RateLimitMiddleware(opts)
+	require.NoError(t, err)
+	return middleware(next)
+}
+
+// serveFrom serves a GET request of path from the client IP.
+func serveFrom(handler http.Handler, ip, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	limit := RateLimit{Requests: 60, Period: time.Minute, Burst: 2}
+
+	result, err := store.Take(t.Context(), "k", limit)
+	require.NoError(t, err)
+	assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, result)
+
+	result, _ = store.Take(t.Context(), "k", limit)
+	assert.True(t, result.Allowed)
+	result, _ = store.Take(t.Context(), "k", limit)
+	assert.Equal(t, RateLimitResult{Limit: 2, Reset: 2 * time.Second, RetryAfter: time.Second}, result)
+
+	clock.now = clock.now.Add(1500 * time.Millisecond)
+	result, _ = store.Take(t.Context(), "k", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, _ = store.Take(t.Context(), "other", limit)
+	assert.True(t, result.Allowed)
+
+	clock.now = clock.now.Add(2 * rateLimitSweepInterval)
+	_, _ = store.Take(t.Context(), "k", limit)
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Run("limits the clients by IP", func(t *testing.T) {
+		store, clock := newTestRateLimitStore()
+		handler := newRateLimited(t, RateLimitOptions{Default: RateLimit{Requests: 2, Period: 10 * time.Second}, Store: store}, okHandler())
+
+		w := serveFrom(handler, "192.0.2.1", "/orders")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "5", w.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "2;w=10", w.Header().Get("RateLimit-Policy"))
+
+		serveFrom(handler, "192.0.2.1", "/orders")
+		w = serveFrom(handler, "192.0.2.1", "/orders")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "5", w.Header().Get("Retry-After"))
+		assert.Equal(t, problemDetails{
+			Type:     "about:blank",
+			Title:    "Too Many Requests",
+			Status:   http.StatusTooManyRequests,
+			Detail:   "rate limit exceeded",
+			Instance: "/orders",
+		}, decodeProblem(t, w))
+
+		assert.Equal(t, http.StatusOK, serveFrom(handler, "192.0.2.2", "/orders").Code)
+		clock.now = clock.now.Add(5 * time.Second)
+		assert.Equal(t, http.StatusOK, serveFrom(handler, "192.0.2.1", "/orders").Code)
+	})
+
+	t.Run("limits the users by tier", func(t *testing.T) {
+		opts := RateLimitOptions{
+			Default: RateLimit{Requests: 1},
+			Tiers:   map[string]RateLimit{"premium": {Requests: 3}},
+		}
+		for name, tt := range map[string]struct {
+			user    User
+			allowed int
+		}{
+			"premium":      {user: claimsUser{id: "u1", claims: map[string]any{"tier": "premium"}}, allowed: 3},
+			"unknown tier": {user: claimsUser{id: "u2", claims: map[string]any{"tier": "gold"}}, allowed: 1},
+			"no tier":      {user: testUser{id: "u3"}, allowed: 1},
+		} {
+			t.Run(name, func(t *testing.T) {
+				handler := asUser(tt.user)(newRateLimited(t, opts, okHandler()))
+				allowed := 0
+				for i := range 5 {
+					// the users are limited whatever their IP
+					if serveFrom(handler, fmt.Sprintf("192.0.2.%d", i+1), "/orders").Code == http.StatusOK {
+						allowed++
+					}
+				}
+				assert.Equal(t, tt.allowed, allowed)
+			})
+		}
+	})
+
+	t.Run("limits the API keys separately", func(t *testing.T) {
+		keys, err := NewApiKeyMapFromPlainMap(map[string]User{"key-1": testUser{id: "u1"}, "key-2": testUser{id: "u1"}})
+		require.NoError(t, err)
+		handler := AuthenticationMiddleware(MultipleUserApiKeyAuthenticationFunc(keys, ""))(
+			newRateLimited(t, RateLimitOptions{Default: RateLimit{Requests: 1}}, okHandler()))
+
+		for key, wantCode := range map[string]int{"key-1": http.StatusOK, "key-2": http.StatusOK} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, apiKeyRequest(key))
+			assert.Equal(t, wantCode, w.Code, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, apiKeyRequest("key-1"))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("applies the route limits", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerRateLimitRequestsKey, 2)
+		viper.Set(configs.HTTPServerRateLimitRoutesKey, []map[string]any{
+			{"pattern": "POST /login", "requests": 1, "period": "1h"},
+			{"pattern": "GET /health", "requests": 0},
+		})
+		opts := RateLimitOptionsFromConfig()
+		require.Len(t, opts.Routes, 2)
+		assert.Equal(t, time.Hour, opts.Routes[0].Period)
+
+		mux := http.NewServeMux()
+		mux.Handle("POST /login", okHandler())
+		mux.Handle("GET /health", okHandler())
+		mux.Handle("GET /orders", okHandler())
+		handler := newRateLimited(t, opts, mux)
+
+		login := func() int {
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusOK, login())
+		assert.Equal(t, http.StatusTooManyRequests, login())
+		for range 3 {
+			w := serveFrom(handler, "192.0.2.1", "/health")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+		// the route buckets are not shared with the default one
+		assert.Equal(t, http.StatusOK, serveFrom(handler, "192.0.2.1", "/orders").Code)
+		assert.Equal(t, "0", serveFrom(handler, "192.0.2.1", "/orders").Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("allows the requests when the store fails", func(t *testing.T) {
+		handler := newRateLimited(t, RateLimitOptions{Default: RateLimit{Requests: 1}, Store: failingRateLimitStore{}}, okHandler())
+		assert.Equal(t, http.StatusOK, serveFrom(handler, "192.0.2.1", "/orders").Code)
+	})
+
+	t.Run("rejects invalid configurations", func(t *testing.T) {
+		for name, opts := range map[string]RateLimitOptions{
+			"trusted proxy": {TrustedProxies: []string{"10.0.0.0/33"}},
+			"route burst":   {Routes: []RouteRateLimit{{Pattern: "GET /", RateLimit: RateLimit{Requests: 1, Burst: -1}}}},
+			"tier period":   {Tiers: map[string]RateLimit{"free": {Requests: 1, Period: -time.Second}}},
+		} {
+			_, err := RateLimitMiddleware(opts)
+			assert.ErrorIs(t, err, ErrInvalidRateLimitConfig, name)
+		}
+	})
+
+	t.Run("rejects undecodable configurations", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerRateLimitRequestsKey, 2)
+		viper.Set(configs.HTTPServerRateLimitRoutesKey, []map[string]any{{"pattern": "POST /login", "period": "hourly"}})
+		_, err := RateLimitMiddleware(RateLimitOptionsFromConfig())
+		assert.ErrorIs(t, err, ErrInvalidRateLimitConfig)
+		assert.ErrorContains(t, err, configs.HTTPServerRateLimitRoutesKey)
+	})
+}
+
+func TestForwardedClientIP(t *testing.T) {
+	var trusted []netip.Prefix
+	for _, proxy := range []string{"10.0.0.0/8", "192.0.2.10"} {
+		prefix, err := parsePrefix(proxy)
+		require.NoError(t, err)
+		trusted = append(trusted, prefix)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "198.51.100.7:1234", want: "198.51.100.7"},
+		{name: "untrusted proxy", remoteAddr: "198.51.100.7:1234", headers: map[string]string{"X-Forwarded-For": "203.0.113.5"}, want: "198.51.100.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", headers: map[string]string{"X-Forwarded-For": "203.0.113.5"}, want: "203.0.113.5"},
+		{name: "spoofed entries", remoteAddr: "10.1.2.3:1234", headers: map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.5, 192.0.2.10"}, want: "203.0.113.5"},
+		{name: "invalid entry", remoteAddr: "10.1.2.3:1234", headers: map[string]string{"X-Forwarded-For": "bogus, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "real IP", remoteAddr: "192.0.2.10:1234", headers: map[string]string{"X-Real-IP": "203.0.113.9"}, want: "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authRequest(tt.headers)
+			req.RemoteAddr = tt.remoteAddr
+			assert.Equal(t, tt.want, forwardedClientIP(req, trusted))
+		})
+	}
+}
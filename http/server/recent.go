@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/logs"
+)
+
+type recentLogsHandler struct {
+	recent *logs.RecentLogs
+}
+
+// RecentLogsHandler returns the debug endpoint serving the records kept by
+// recent (the default logs.RecentLogs when nil), protected by the
+// AuthenticationMiddleware using authFunc. GET requests return the records,
+// oldest first, as a JSON array or as text lines with `format=text` (or an
+// `Accept: text/plain` header), filtered by the query parameters:
+//   - `level`: minimum level (e.g. `warn`);
+//   - `logger`: logger name, including its children;
+//   - `trace_id`: trace of the records;
+//   - `q`: substring of the message or attribute values;
+//   - `since`: RFC 3339 time or duration (e.g. `5m`) of the oldest record;
+//   - `limit`: maximum number of records, keeping the most recent ones.
+func RecentLogsHandler(authFunc UserAuthenticationFunc, recent *logs.RecentLogs) http.Handler {
+	if authFunc == nil {
+		authFunc = func(_ *http.Request) (User, error) {
+			return nil, ErrNotAuthorized
+		}
+	}
+	return AuthenticationMiddleware(authFunc)(&recentLogsHandler{recent: recent})
+}
+
+func (h *recentLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	recent := h.recent
+	if recent == nil {
+		recent = logs.DefaultRecentLogs()
+	}
+	if recent == nil {
+		http.Error(w, "recent logs are disabled", http.StatusNotFound)
+		return
+	}
+
+	filter, err := recentFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records := recent.Records(filter)
+
+	format := r.URL.Query().Get("format")
+	if format == "text" || (format == "" && strings.HasPrefix(r.Header.Get("Accept"), "text/plain")) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, rec := range records {
+			_, _ = fmt.Fprintln(w, recentLine(rec))
+		}
+		return
+	}
+	if records == nil {
+		records = []logs.RecentRecord{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(records)
+}
+
+func recentFilter(r *http.Request) (logs.RecentFilter, error) {
+	q := r.URL.Query()
+	filter := logs.RecentFilter{
+		Logger:   q.Get("logger"),
+		TraceID:  q.Get("trace_id"),
+		Contains: q.Get("q"),
+	}
+	if level := q.Get("level"); level != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return filter, fmt.Errorf("invalid level: %w", err)
+		}
+		filter.Level = l
+	}
+	if since := q.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().Add(-d)
+		} else if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return filter, fmt.Errorf("invalid since: %q", since)
+		}
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid limit: %q", limit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// recentLine formats a record as a logfmt like line.
+func recentLine(rec logs.RecentRecord) string {
+	var sb strings.Builder
+	sb.WriteString(rec.Time.Format(time.RFC3339Nano))
+	sb.WriteByte(' ')
+	sb.WriteString(strings.ToUpper(rec.Level))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Quote(rec.Message))
+	if rec.TraceID != "" {
+		sb.WriteString(" trace_id=" + rec.TraceID + " span_id=" + rec.SpanID)
+	}
+	for _, k := range slices.Sorted(maps.Keys(rec.Attrs)) {
+		v := fmt.Sprint(rec.Attrs[k])
+		if strings.ContainsAny(v, " \"=") || v == "" {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(" " + k + "=" + v)
+	}
+	return sb.String()
+}
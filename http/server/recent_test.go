@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eldius/initial-config-go/logs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentLogsHandler(t *testing.T) {
+	const apiKey = "admin-key"
+	recent := logs.NewRecentLogs(logs.RecentOptions{Level: slog.LevelDebug})
+	l := slog.New(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+	l.Debug("cache miss", logs.LoggerNameKey, "cache")
+	l.Warn("slow query", logs.LoggerNameKey, "db", "table", "users")
+	l.Error("query failed", logs.LoggerNameKey, "db")
+
+	h := RecentLogsHandler(SingleUserApiKeyAuthenticationFunc(apiKey, "", testUser{id: "admin"}), recent)
+	call := func(t *testing.T, method, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequestWithContext(t.Context(), method, target, nil)
+		req.Header.Set(DefaultXApiKeyHeaderName, apiKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) []logs.RecentRecord {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code)
+		var records []logs.RecentRecord
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &records))
+		return records
+	}
+
+	t.Run("returns the records as JSON", func(t *testing.T) {
+		records := decode(t, call(t, http.MethodGet, "/"))
+		require.Len(t, records, 3)
+		assert.Equal(t, "cache miss", records[0].Message)
+		assert.Equal(t, "debug", records[0].Level)
+		assert.Equal(t, "cache", records[0].Logger)
+	})
+
+	t.Run("filters the records", func(t *testing.T) {
+		records := decode(t, call(t, http.MethodGet, "/?level=warn&logger=db&q=users&since=1h&limit=10"))
+		require.Len(t, records, 1)
+		assert.Equal(t, "slow query", records[0].Message)
+
+		assert.Empty(t, decode(t, call(t, http.MethodGet, "/?trace_id=unknown")))
+	})
+
+	t.Run("returns the records as text", func(t *testing.T) {
+		rec := call(t, http.MethodGet, "/?format=text&limit=1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Regexp(t, `^\S+ ERROR "query failed" pkg=db\n$`, rec.Body.String())
+	})
+
+	t.Run("rejects invalid filters", func(t *testing.T) {
+		for _, target := range []string{"/?level=loud", "/?since=yesterday", "/?limit=-1"} {
+			assert.Equal(t, http.StatusBadRequest, call(t, http.MethodGet, target).Code, target)
+		}
+	})
+
+	t.Run("only serves GET requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, call(t, http.MethodPost, "/").Code)
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("is not found when disabled", func(t *testing.T) {
+		logs.SetDefaultRecentLogs(nil)
+		h := RecentLogsHandler(SingleUserApiKeyAuthenticationFunc(apiKey, "", testUser{id: "admin"}), nil)
+		req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil)
+		req.Header.Set(DefaultXApiKeyHeaderName, apiKey)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/eldius/initial-config-go/telemetry"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Defaults used when the `http.server.*` config keys are not defined.
+const (
+	DefaultServerAddress           = ":8080"
+	DefaultServerReadTimeout       = 30 * time.Second
+	DefaultServerReadHeaderTimeout = 10 * time.Second
+	DefaultServerWriteTimeout      = 30 * time.Second
+	DefaultServerIdleTimeout       = 120 * time.Second
+	DefaultServerShutdownTimeout   = 30 * time.Second
+)
+
+// ErrServerShutdownTimeout is returned when in-flight requests are still
+// running at the end of the shutdown grace period.
+var ErrServerShutdownTimeout = errors.New("http server shutdown grace period exceeded")
+
+// ServerOption configures the server created by New.
+type ServerOption func(*Server)
+
+// WithAddress defines the address the server listens to (like `:8080` or
+// `127.0.0.1:0` for an ephemeral port).
+func WithAddress(addr string) ServerOption {
+	return func(s *Server) {
+		s.srv.Addr = addr
+	}
+}
+
+// WithShutdownTimeout defines the grace period given to in-flight requests
+// when the server shuts down.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithShutdownHooks defines the functions run once the server is stopped
+// (telemetry.TelemetryForceFlush by default).
+func WithShutdownHooks(hooks ...func(context.Context) error) ServerOption {
+	return func(s *Server) {
+		s.hooks = hooks
+	}
+}
+
+// WithSignals defines the signals triggering the shutdown (SIGTERM and
+// SIGINT by default).
+func WithSignals(signals ...os.Signal) ServerOption {
+	return func(s *Server) {
+		s.signals = signals
+	}
+}
+
+// Server is an HTTP server shutting down gracefully.
+type Server struct {
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	hooks           []func(context.Context) error
+	signals         []os.Signal
+	tlsCfg          TLSConfig
+	meterProvider   metric.MeterProvider
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// New creates a server for mux, instrumented with TelemetryMiddleware and
+// configured by the `http.server.*` config keys (address, timeouts, max
+// header bytes, shutdown grace period and TLS), then by opts. It serves TLS
+// when a certificate file is configured (see NewTLSConfig).
+func New(mux *http.ServeMux, opts ...ServerOption) *Server {
+	cfg := configs.GetHTTPServer()
+	s := &Server{
+		srv: &http.Server{
+			Addr:              orDefault(cfg.Address, DefaultServerAddress),
+			Handler:           TelemetryMiddleware(mux),
+			ReadTimeout:       orDefault(cfg.ReadTimeout, DefaultServerReadTimeout),
+			ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, DefaultServerReadHeaderTimeout),
+			WriteTimeout:      orDefault(cfg.WriteTimeout, DefaultServerWriteTimeout),
+			IdleTimeout:       orDefault(cfg.IdleTimeout, DefaultServerIdleTimeout),
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		shutdownTimeout: orDefault(cfg.ShutdownTimeout, DefaultServerShutdownTimeout),
+		hooks:           []func(context.Context) error{telemetry.TelemetryForceFlush},
+		signals:         []os.Signal{syscall.SIGTERM, os.Interrupt},
+		tlsCfg:          cfg.TLS,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.srv.ErrorLog = serverErrorLog(s.meterProvider)
+	return s
+}
+
+func orDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
+// HTTPServer returns the underlying http.Server, to be customized before Run.
+func (s *Server) HTTPServer() *http.Server {
+	return s.srv
+}
+
+// Listen loads the TLS configuration and starts listening to the configured
+// address, so Addr returns the bound address (Run listens when not done yet).
+func (s *Server) Listen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+	if s.tlsCfg.CertFile != "" {
+		tlsConfig, err := NewTLSConfig(s.tlsCfg)
+		if err != nil {
+			return err
+		}
+		s.srv.TLSConfig = tlsConfig
+	}
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("listening to %s: %w", s.srv.Addr, err)
+	}
+	s.listener = l
+	return nil
+}
+
+// Addr returns the address the server listens to, nil before Listen.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Run serves the requests until ctx is canceled or a shutdown signal is
+// received, then stops accepting connections, waits for the in-flight
+// requests during the shutdown grace period and runs the shutdown hooks
+// (flushing the telemetry). It returns nil after a graceful shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, s.signals...)
+	defer stop()
+
+	if err := s.Listen(); err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.InfoContext(ctx, "HTTP server listening", "address", s.Addr().String(), "tls", s.srv.TLSConfig != nil)
+		if s.srv.TLSConfig != nil {
+			// the certificate is served by TLSConfig.GetCertificate
+			serveErr <- s.srv.ServeTLS(s.listener, "", "")
+			return
+		}
+		serveErr <- s.srv.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serving http: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.InfoContext(ctx, "HTTP server shutting down", "grace_period", s.shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.srv.Shutdown(shutdownCtx); err != nil {
+		_ = s.srv.Close()
+		errs = append(errs, fmt.Errorf("%w: %w", ErrServerShutdownTimeout, err))
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("serving http: %w", err))
+	}
+
+	// the hooks get their own grace period, as the requests may have used it all
+	hooksCtx, cancelHooks := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
+	defer cancelHooks()
+	for _, hook := range s.hooks {
+		if err := hook(hooksCtx); err != nil {
+			errs = append(errs, fmt.Errorf("running shutdown hook: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
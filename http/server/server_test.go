@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer runs a server on an ephemeral port, returning its URL and the
+// result of Run.
+func startServer(t *testing.T, ctx context.Context, mux *http.ServeMux, opts ...ServerOption) (string, <-chan error) {
+	t.Helper()
+	s := New(mux, append([]ServerOption{WithAddress("127.0.0.1:0"), WithShutdownHooks()}, opts...)...)
+	require.NoError(t, s.Listen())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	return "http://" + s.Addr().String(), done
+}
+
+func get(t *testing.T, url string) (int, string, error) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+func TestServer(t *testing.T) {
+	t.Run("drains in-flight requests and flushes on cancellation", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /slow", func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			_, _ = w.Write([]byte("done"))
+		})
+		var flushed bool
+		ctx, cancel := context.WithCancel(t.Context())
+		url, done := startServer(t, ctx, mux, WithShutdownHooks(func(context.Context) error {
+			flushed = true
+			return nil
+		}))
+
+		type result struct {
+			code int
+			body string
+			err  error
+		}
+		inFlight := make(chan result, 1)
+		go func() {
+			code, body, err := get(t, url+"/slow")
+			inFlight <- result{code, body, err}
+		}()
+		<-started
+		cancel()
+
+		// new connections are refused once the server stops accepting
+		assert.Eventually(t, func() bool {
+			_, _, err := get(t, url+"/slow")
+			return err != nil
+		}, time.Second, 5*time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("Run should wait for the in-flight request")
+		default:
+		}
+
+		close(release)
+		r := <-inFlight
+		require.NoError(t, r.err)
+		assert.Equal(t, http.StatusOK, r.code)
+		assert.Equal(t, "done", r.body)
+		assert.NoError(t, <-done)
+		assert.True(t, flushed)
+	})
+
+	t.Run("stops waiting after the grace period", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /stuck", func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		})
+		ctx, cancel := context.WithCancel(t.Context())
+		url, done := startServer(t, ctx, mux, WithShutdownTimeout(20*time.Millisecond))
+
+		go func() { _, _, _ = get(t, url+"/stuck") }()
+		<-started
+		cancel()
+		assert.ErrorIs(t, <-done, ErrServerShutdownTimeout)
+	})
+
+	t.Run("shuts down on the configured signals", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		})
+		url, done := startServer(t, t.Context(), mux, WithSignals(syscall.SIGUSR1))
+
+		// SIGUSR1 stands for SIGTERM, which would stop the test binary without a
+		// handler; the handler is registered before serving
+		code, body, err := get(t, url+"/health")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", body)
+
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("the server should stop on the signal")
+		}
+	})
+
+	t.Run("reads the config", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.HTTPServerAddressKey, "127.0.0.1:9999")
+		viper.Set(configs.HTTPServerReadTimeoutKey, "5s")
+		viper.Set(configs.HTTPServerMaxHeaderBytesKey, 4096)
+		viper.Set(configs.HTTPServerShutdownTimeoutKey, "3s")
+
+		s := New(http.NewServeMux())
+		assert.Equal(t, "127.0.0.1:9999", s.HTTPServer().Addr)
+		assert.Equal(t, 5*time.Second, s.HTTPServer().ReadTimeout)
+		assert.Equal(t, DefaultServerWriteTimeout, s.HTTPServer().WriteTimeout)
+		assert.Equal(t, 4096, s.HTTPServer().MaxHeaderBytes)
+		assert.Equal(t, 3*time.Second, s.shutdownTimeout)
+		assert.Nil(t, s.Addr())
+	})
+
+	t.Run("fails when the address is in use", func(t *testing.T) {
+		url, _ := startServer(t, t.Context(), http.NewServeMux())
+		s := New(http.NewServeMux(), WithAddress(url[len("http://"):]))
+		assert.Error(t, s.Run(t.Context()))
+	})
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	serverMeterName = "github.com/eldius/initial-config-go/http/server"
+
+	// DefaultTLSReloadInterval is how often the certificate files are
+	// checked for changes when not configured.
+	DefaultTLSReloadInterval = 10 * time.Second
+)
+
+// ErrInvalidTLSConfig is returned when the server TLS configuration is invalid.
+var ErrInvalidTLSConfig = errors.New("invalid http server TLS configuration")
+
+// TLSConfig is the TLS configuration of the server (see the
+// `http.server.tls.*` config keys).
+type TLSConfig = configs.HTTPServerTLS
+
+// WithTLS serves TLS with the given configuration, overriding the
+// `http.server.tls.*` config keys.
+func WithTLS(cfg TLSConfig) ServerOption {
+	return func(s *Server) {
+		s.tlsCfg = cfg
+	}
+}
+
+// WithServerMeterProvider defines the meter provider creating the server metrics
+// (the global one by default).
+func WithServerMeterProvider(mp metric.MeterProvider) ServerOption {
+	return func(s *Server) {
+		s.meterProvider = mp
+	}
+}
+
+// NewTLSConfig creates a tls.Config serving the certificate of cfg, reloaded
+// when its files change, with the client certificate, minimum version and
+// cipher suites policies of cfg.
+func NewTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("%w: the certificate and key files are required", ErrInvalidTLSConfig)
+	}
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, cfg.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if cfg.MinVersion != "" {
+		if tlsConfig.MinVersion, err = parseTLSVersion(cfg.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.CipherSuites) > 0 {
+		if tlsConfig.CipherSuites, err = parseCipherSuites(cfg.CipherSuites); err != nil {
+			return nil, err
+		}
+	}
+
+	clientAuth := cfg.ClientAuth
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA file: %w", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificate found in %s", ErrInvalidTLSConfig, cfg.ClientCAFile)
+		}
+		if clientAuth == "" {
+			clientAuth = configs.TLSClientAuthRequireAndVerify
+		}
+	}
+	if tlsConfig.ClientAuth, err = parseClientAuth(clientAuth); err != nil {
+		return nil, err
+	}
+	if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven && tlsConfig.ClientCAs == nil {
+		return nil, fmt.Errorf("%w: client_auth %s requires a client CA file", ErrInvalidTLSConfig, clientAuth)
+	}
+	return tlsConfig, nil
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown TLS version %q", ErrInvalidTLSConfig, v)
+	}
+}
+
+// parseCipherSuites returns the IDs of the named cipher suites, rejecting the
+// insecure ones.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		var found bool
+		for _, suite := range tls.CipherSuites() {
+			if strings.EqualFold(suite.Name, name) {
+				ids = append(ids, suite.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: unknown or insecure cipher suite %q", ErrInvalidTLSConfig, name)
+		}
+	}
+	return ids, nil
+}
+
+func parseClientAuth(policy string) (tls.ClientAuthType, error) {
+	switch policy {
+	case "", configs.TLSClientAuthNone:
+		return tls.NoClientCert, nil
+	case configs.TLSClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case configs.TLSClientAuthRequire:
+		return tls.RequireAnyClientCert, nil
+	case configs.TLSClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case configs.TLSClientAuthRequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown client_auth %q", ErrInvalidTLSConfig, policy)
+	}
+}
+
+// certReloader loads the certificate again when its files change, checking
+// them at most once per interval during the handshakes.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTimes  [2]time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	if interval <= 0 {
+		interval = DefaultTLSReloadInterval
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTimes); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) statFiles() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return modTimes, fmt.Errorf("reading certificate file: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *certReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < r.interval {
+		return r.cert, nil
+	}
+	r.checkedAt = time.Now()
+	modTimes, err := r.statFiles()
+	if err != nil || modTimes == r.modTimes {
+		// a rotation may be in progress, the current certificate is kept
+		return r.cert, nil
+	}
+	if err := r.load(modTimes); err != nil {
+		slog.Error("failed to reload TLS certificate, keeping the current one", "cert_file", r.certFile, "error", err)
+		return r.cert, nil
+	}
+	slog.Info("TLS certificate reloaded", "cert_file", r.certFile)
+	return r.cert, nil
+}
+
+// serverErrorLog returns the http.Server error logger, logging its messages
+// with slog and counting the TLS handshake failures.
+func serverErrorLog(mp metric.MeterProvider) *log.Logger {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	handshakeErrors, err := mp.Meter(serverMeterName).Int64Counter(
+		"http.server.tls.handshake.errors",
+		metric.WithDescription("Number of failed TLS handshakes"),
+		metric.WithUnit("{handshake}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return log.New(&serverErrorWriter{handshakeErrors: handshakeErrors}, "", 0)
+}
+
+type serverErrorWriter struct {
+	handshakeErrors metric.Int64Counter
+}
+
+func (w *serverErrorWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSpace(p))
+	if strings.Contains(msg, "TLS handshake error") {
+		if w.handshakeErrors != nil {
+			w.handshakeErrors.Add(context.Background(), 1)
+		}
+		slog.Warn("TLS handshake failed", "error", strings.TrimPrefix(msg, "http: "))
+		return len(p), nil
+	}
+	slog.Error("HTTP server error", "error", strings.TrimPrefix(msg, "http: "))
+	return len(p), nil
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testCA issues the certificates of the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, pool: x509.NewCertPool(), file: filepath.Join(t.TempDir(), "ca.pem")}
+	ca.pool.AddCert(cert)
+	require.NoError(t, os.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return ca
+}
+
+// issue returns a certificate signed by the CA for the common name and SAN URIs.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		tmpl.URIs = append(tmpl.URIs, u)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+// writeKeyPair writes the certificate and key PEM files, returning their paths.
+func writeKeyPair(t *testing.T, dir string, cert tls.Certificate) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func tlsClient(ca *testCA, cfg *tls.Config) *http.Client {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg.RootCAs = ca.pool
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+}
+
+func helloMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	return mux
+}
+
+// startTLSServer runs a TLS server on an ephemeral port, returning its URL.
+func startTLSServer(t *testing.T, ctx context.Context, mux *http.ServeMux, opts ...ServerOption) (string, <-chan error) {
+	t.Helper()
+	url, done := startServer(t, ctx, mux, opts...)
+	return strings.Replace(url, "http://", "https://", 1), done
+}
+
+func TestServerTLS(t *testing.T) {
+	ca := newTestCA(t)
+
+	t.Run("reloads the certificate when the files change", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, "first", x509.ExtKeyUsageServerAuth))
+		url, _ := startTLSServer(t, t.Context(), helloMux(), WithTLS(TLSConfig{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ReloadInterval: time.Millisecond,
+		}))
+		client := tlsClient(ca, nil)
+		servedCN := func() string {
+			resp, err := client.Get(url + "/hello")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			return resp.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		assert.Equal(t, "first", servedCN())
+
+		writeKeyPair(t, dir, ca.issue(t, "rotated", x509.ExtKeyUsageServerAuth))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+		require.NoError(t, os.Chtimes(keyFile, future, future))
+		assert.Eventually(t, func() bool { return servedCN() == "rotated" }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("verifies the client certificates", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		var logs lockedBuffer
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+		t.Cleanup(func() { slog.SetDefault(previous) })
+
+		certFile, keyFile := writeKeyPair(t, t.TempDir(), ca.issue(t, "server", x509.ExtKeyUsageServerAuth))
+		url, _ := startTLSServer(t, t.Context(), helloMux(),
+			WithServerMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+			WithTLS(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: ca.file}),
+		)
+
+		clientCert := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+		resp, err := tlsClient(ca, &tls.Config{Certificates: []tls.Certificate{clientCert}}).Get(url + "/hello")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, err = tlsClient(ca, nil).Get(url + "/hello")
+		assert.Error(t, err)
+
+		assert.Eventually(t, func() bool {
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == "http.server.tls.handshake.errors" {
+						return m.Data.(metricdata.Sum[int64]).DataPoints[0].Value == 1
+					}
+				}
+			}
+			return false
+		}, time.Second, 5*time.Millisecond)
+		assert.Contains(t, logs.String(), "TLS handshake failed")
+	})
+
+	t.Run("enforces the minimum version", func(t *testing.T) {
+		certFile, keyFile := writeKeyPair(t, t.TempDir(), ca.issue(t, "server", x509.ExtKeyUsageServerAuth))
+		url, _ := startTLSServer(t, t.Context(), helloMux(), WithTLS(TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}))
+
+		_, err := tlsClient(ca, &tls.Config{MaxVersion: tls.VersionTLS12}).Get(url + "/hello")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects invalid configurations", func(t *testing.T) {
+		certFile, keyFile := writeKeyPair(t, t.TempDir(), ca.issue(t, "server", x509.ExtKeyUsageServerAuth))
+		for name, cfg := range map[string]TLSConfig{
+			"missing key":         {CertFile: certFile},
+			"unknown version":     {CertFile: certFile, KeyFile: keyFile, MinVersion: "2.0"},
+			"insecure cipher":     {CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			"verify without CA":   {CertFile: certFile, KeyFile: keyFile, ClientAuth: "require_and_verify"},
+			"unknown client auth": {CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"},
+			"invalid client CA":   {CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile},
+		} {
+			_, err := NewTLSConfig(cfg)
+			assert.ErrorIs(t, err, ErrInvalidTLSConfig, name)
+		}
+
+		cfg, err := NewTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}})
+		require.NoError(t, err)
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	})
+}
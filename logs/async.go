@@ -0,0 +1,293 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+// ErrInvalidAsyncConfig is returned when the asynchronous handler configuration is invalid.
+var ErrInvalidAsyncConfig = errors.New("invalid async log configuration")
+
+const defaultAsyncBufferSize = 1024
+
+var (
+	asyncHandlersMu sync.Mutex
+	asyncHandlers   []*AsyncHandler
+)
+
+// AsyncOptions configures the asynchronous handler.
+type AsyncOptions struct {
+	// BufferSize is the number of records buffered before the overflow
+	// policy applies (1024 when zero).
+	BufferSize int
+	// Overflow is the policy applied when the buffer is full (block,
+	// drop_newest, drop_oldest or drop_below_level, see configs.LogAsyncOverflow*).
+	Overflow string
+	// DropLevel is the level below which records are dropped when the
+	// buffer is full, using the drop_below_level policy (the others block).
+	DropLevel slog.Level
+}
+
+// AsyncOptionsFromConfig returns the asynchronous handler options defined by
+// the `log.async.*` config keys, and whether it is enabled.
+func AsyncOptionsFromConfig() (AsyncOptions, bool) {
+	cfg := configs.GetLogAsync()
+	return AsyncOptions{
+		BufferSize: cfg.BufferSize,
+		Overflow:   cfg.Overflow,
+		DropLevel:  parseLogLevel(cfg.DropLevel),
+	}, cfg.Enabled
+}
+
+type asyncEntry struct {
+	seq    uint64
+	h      slog.Handler
+	ctx    context.Context
+	record slog.Record
+}
+
+// asyncQueue is the ring buffer shared by an AsyncHandler and its derived handlers.
+type asyncQueue struct {
+	opts AsyncOptions
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	entries  []asyncEntry
+	head     int
+	count    int
+	// pushed is the sequence number of the last buffered record, and
+	// written the one of the last record written.
+	pushed  uint64
+	written uint64
+	// progress is closed when a record is written, if a Flush waits for it.
+	progress chan struct{}
+	closed   bool
+	done     chan struct{}
+
+	dropped atomic.Uint64
+}
+
+// AsyncHandler writes records through the wrapped handler from a background
+// goroutine, so logging does not block on I/O.
+type AsyncHandler struct {
+	h     slog.Handler
+	queue *asyncQueue
+}
+
+// NewAsyncHandler wraps h, buffering the records in a bounded ring buffer
+// written by a background goroutine. Buffered records are written by Flush
+// and Close; FlushAsyncHandlers and CloseAsyncHandlers (called by the
+// telemetry flush and shutdown functions) apply to all the created handlers
+// not closed yet.
+func NewAsyncHandler(h slog.Handler, opts AsyncOptions) (*AsyncHandler, error) {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultAsyncBufferSize
+	}
+	switch opts.Overflow {
+	case "":
+		opts.Overflow = configs.LogAsyncOverflowBlock
+	case configs.LogAsyncOverflowBlock, configs.LogAsyncOverflowDropNewest,
+		configs.LogAsyncOverflowDropOldest, configs.LogAsyncOverflowDropBelowLevel:
+	default:
+		return nil, fmt.Errorf("%w: unknown overflow policy %q", ErrInvalidAsyncConfig, opts.Overflow)
+	}
+
+	q := &asyncQueue{
+		opts:    opts,
+		entries: make([]asyncEntry, opts.BufferSize),
+		done:    make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	go q.run()
+
+	a := &AsyncHandler{h: h, queue: q}
+	asyncHandlersMu.Lock()
+	asyncHandlers = append(asyncHandlers, a)
+	asyncHandlersMu.Unlock()
+	return a, nil
+}
+
+func (a *AsyncHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return a.h.Enabled(ctx, level)
+}
+
+// Handle buffers the record. Once the handler is closed, records are written
+// synchronously.
+func (a *AsyncHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := asyncEntry{h: a.h, ctx: context.WithoutCancel(ctx), record: record.Clone()}
+	if !a.queue.push(entry) {
+		return a.h.Handle(ctx, record)
+	}
+	return nil
+}
+
+func (a *AsyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AsyncHandler{h: a.h.WithAttrs(attrs), queue: a.queue}
+}
+
+func (a *AsyncHandler) WithGroup(name string) slog.Handler {
+	return &AsyncHandler{h: a.h.WithGroup(name), queue: a.queue}
+}
+
+// Dropped returns the number of records dropped by the overflow policy.
+func (a *AsyncHandler) Dropped() uint64 {
+	return a.queue.dropped.Load()
+}
+
+// Flush waits until the records buffered so far are written, whatever is
+// logged meanwhile.
+func (a *AsyncHandler) Flush(ctx context.Context) error {
+	q := a.queue
+	q.mu.Lock()
+	target := q.pushed
+	for q.written < target {
+		if q.progress == nil {
+			q.progress = make(chan struct{})
+		}
+		progress := q.progress
+		q.mu.Unlock()
+
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return fmt.Errorf("flushing async log handler: %w", ctx.Err())
+		}
+		q.mu.Lock()
+	}
+	q.mu.Unlock()
+	return nil
+}
+
+// Close writes the buffered records and stops the background goroutine,
+// unregistering the handler from FlushAsyncHandlers and CloseAsyncHandlers.
+// Records handled afterward are written synchronously.
+func (a *AsyncHandler) Close(ctx context.Context) error {
+	q := a.queue
+	asyncHandlersMu.Lock()
+	asyncHandlers = slices.DeleteFunc(asyncHandlers, func(h *AsyncHandler) bool { return h.queue == q })
+	asyncHandlersMu.Unlock()
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("closing async log handler: %w", ctx.Err())
+	}
+}
+
+// push buffers the entry, applying the overflow policy when the buffer is
+// full. It returns false when the queue is closed.
+func (q *asyncQueue) push(entry asyncEntry) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && q.count == len(q.entries) {
+		switch q.opts.Overflow {
+		case configs.LogAsyncOverflowDropNewest:
+			q.dropped.Add(1)
+			return true
+		case configs.LogAsyncOverflowDropOldest:
+			q.entries[q.head] = asyncEntry{}
+			q.head = (q.head + 1) % len(q.entries)
+			q.count--
+			q.dropped.Add(1)
+		case configs.LogAsyncOverflowDropBelowLevel:
+			if entry.record.Level < q.opts.DropLevel {
+				q.dropped.Add(1)
+				return true
+			}
+			q.notFull.Wait()
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return false
+	}
+
+	q.pushed++
+	entry.seq = q.pushed
+	q.entries[(q.head+q.count)%len(q.entries)] = entry
+	q.count++
+	q.notEmpty.Signal()
+	return true
+}
+
+// run writes the buffered records until the queue is closed and drained.
+func (q *asyncQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for q.count == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.count == 0 {
+			q.mu.Unlock()
+			return
+		}
+		entry := q.entries[q.head]
+		q.entries[q.head] = asyncEntry{}
+		q.head = (q.head + 1) % len(q.entries)
+		q.count--
+		q.notFull.Signal()
+		q.mu.Unlock()
+
+		_ = entry.h.Handle(entry.ctx, entry.record)
+
+		q.mu.Lock()
+		q.written = entry.seq
+		if q.progress != nil {
+			close(q.progress)
+			q.progress = nil
+		}
+		q.mu.Unlock()
+	}
+}
+
+// FlushAsyncHandlers waits until the records buffered by all the asynchronous
+// handlers are written.
+func FlushAsyncHandlers(ctx context.Context) error {
+	var errs []error
+	for _, a := range registeredAsyncHandlers() {
+		if err := a.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseAsyncHandlers writes the buffered records and stops all the
+// asynchronous handlers.
+func CloseAsyncHandlers(ctx context.Context) error {
+	handlers := registeredAsyncHandlers()
+	var errs []error
+	for _, a := range handlers {
+		if err := a.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func registeredAsyncHandlers() []*AsyncHandler {
+	asyncHandlersMu.Lock()
+	defer asyncHandlersMu.Unlock()
+	return append([]*AsyncHandler(nil), asyncHandlers...)
+}
@@ -0,0 +1,203 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedHandler records the handled messages, blocking until the gate is opened.
+type gatedHandler struct {
+	gate chan struct{}
+	mu   *sync.Mutex
+	msgs *[]string
+}
+
+func newGatedHandler() *gatedHandler {
+	return &gatedHandler{gate: make(chan struct{}), mu: &sync.Mutex{}, msgs: &[]string{}}
+}
+
+func (g *gatedHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (g *gatedHandler) Handle(_ context.Context, r slog.Record) error {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.msgs = append(*g.msgs, r.Message)
+	return nil
+}
+
+func (g *gatedHandler) WithAttrs([]slog.Attr) slog.Handler { return g }
+func (g *gatedHandler) WithGroup(string) slog.Handler      { return g }
+
+func (g *gatedHandler) messages() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), *g.msgs...)
+}
+
+func TestAsyncHandler(t *testing.T) {
+	t.Cleanup(func() { _ = CloseAsyncHandlers(context.Background()) })
+
+	t.Run("writes records in order from the background", func(t *testing.T) {
+		var buf bytes.Buffer
+		h, err := NewAsyncHandler(slog.NewJSONHandler(&buf, nil), AsyncOptions{BufferSize: 4})
+		require.NoError(t, err)
+
+		l := slog.New(h).With("pkg", "test")
+		for range 10 {
+			l.Info("message")
+		}
+		require.NoError(t, h.Flush(context.Background()))
+
+		entries := decodeLogLines(t, &buf)
+		require.Len(t, entries, 10)
+		assert.Equal(t, "test", entries[9]["pkg"])
+		assert.Zero(t, h.Dropped())
+	})
+
+	// fill makes the writer wait on the first record (taken out of the
+	// buffer), then logs the given messages.
+	fill := func(t *testing.T, overflow string, msgs ...string) (*AsyncHandler, *gatedHandler) {
+		t.Helper()
+		g := newGatedHandler()
+		h, err := NewAsyncHandler(g, AsyncOptions{BufferSize: 2, Overflow: overflow, DropLevel: slog.LevelWarn})
+		require.NoError(t, err)
+		l := slog.New(h)
+		l.Info("in flight")
+		assert.Eventually(t, func() bool {
+			h.queue.mu.Lock()
+			defer h.queue.mu.Unlock()
+			return h.queue.count == 0
+		}, time.Second, time.Millisecond)
+		for _, m := range msgs {
+			l.Info(m)
+		}
+		return h, g
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowDropNewest, "1", "2", "3")
+		close(g.gate)
+		require.NoError(t, h.Flush(context.Background()))
+		assert.Equal(t, []string{"in flight", "1", "2"}, g.messages())
+		assert.Equal(t, uint64(1), h.Dropped())
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowDropOldest, "1", "2", "3")
+		close(g.gate)
+		require.NoError(t, h.Flush(context.Background()))
+		assert.Equal(t, []string{"in flight", "2", "3"}, g.messages())
+		assert.Equal(t, uint64(1), h.Dropped())
+	})
+
+	t.Run("drop below level keeps higher levels", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowDropBelowLevel, "1", "2", "3")
+		done := make(chan struct{})
+		go func() {
+			slog.New(h).Warn("warn")
+			close(done)
+		}()
+		close(g.gate)
+		<-done
+		require.NoError(t, h.Flush(context.Background()))
+		assert.Equal(t, []string{"in flight", "1", "2", "warn"}, g.messages())
+		assert.Equal(t, uint64(1), h.Dropped())
+	})
+
+	t.Run("block waits for room", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowBlock, "1", "2")
+		done := make(chan struct{})
+		go func() {
+			slog.New(h).Info("3")
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("logging should block while the buffer is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(g.gate)
+		<-done
+		require.NoError(t, h.Flush(context.Background()))
+		assert.Equal(t, []string{"in flight", "1", "2", "3"}, g.messages())
+	})
+
+	t.Run("flush honors the context", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowBlock)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, h.Flush(ctx), context.DeadlineExceeded)
+		close(g.gate)
+	})
+
+	t.Run("flush does not wait for the records logged meanwhile", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowBlock, "1", "2")
+		go slog.New(h).Info("3")
+
+		flushed := make(chan error, 1)
+		go func() { flushed <- h.Flush(context.Background()) }()
+		assert.Eventually(t, func() bool {
+			h.queue.mu.Lock()
+			defer h.queue.mu.Unlock()
+			return h.queue.progress != nil
+		}, time.Second, time.Millisecond)
+		for range 3 {
+			g.gate <- struct{}{}
+		}
+		select {
+		case err := <-flushed:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("flush should return once the records buffered before it are written")
+		}
+		assert.Equal(t, []string{"in flight", "1", "2"}, g.messages())
+		close(g.gate)
+	})
+
+	t.Run("close writes the buffered records", func(t *testing.T) {
+		h, g := fill(t, configs.LogAsyncOverflowBlock, "1", "2")
+		close(g.gate)
+		require.NoError(t, h.Close(context.Background()))
+		assert.Equal(t, []string{"in flight", "1", "2"}, g.messages())
+
+		slog.New(h).Info("after close")
+		assert.Equal(t, []string{"in flight", "1", "2", "after close"}, g.messages())
+	})
+
+	t.Run("unregisters the closed handlers", func(t *testing.T) {
+		h, err := NewAsyncHandler(slog.NewJSONHandler(&bytes.Buffer{}, nil), AsyncOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, registeredAsyncHandlers(), h)
+
+		require.NoError(t, slog.New(h).With("pkg", "test").Handler().(*AsyncHandler).Close(context.Background()))
+		assert.NotContains(t, registeredAsyncHandlers(), h)
+	})
+
+	t.Run("rejects unknown policies", func(t *testing.T) {
+		_, err := NewAsyncHandler(slog.NewJSONHandler(&bytes.Buffer{}, nil), AsyncOptions{Overflow: "unknown"})
+		assert.ErrorIs(t, err, ErrInvalidAsyncConfig)
+	})
+}
+
+func TestFlushAsyncHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewAsyncHandler(slog.NewJSONHandler(&buf, nil), AsyncOptions{})
+	require.NoError(t, err)
+	slog.New(h).Info("buffered")
+
+	require.NoError(t, FlushAsyncHandlers(context.Background()))
+	assert.Len(t, decodeLogLines(t, &buf), 1)
+
+	slog.New(h).Info("buffered again")
+	require.NoError(t, CloseAsyncHandlers(context.Background()))
+	assert.Contains(t, buf.String(), "buffered again")
+}
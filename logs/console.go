@@ -0,0 +1,322 @@
+package logs
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const consoleTimeFormat = "2006-01-02 15:04:05.000"
+
+// ANSI escape codes used by the console handler.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// ColorMode defines when the console handler colorizes its output.
+type ColorMode int
+
+const (
+	// ColorAuto colorizes the output when writing to a terminal, unless the
+	// NO_COLOR environment variable is set.
+	ColorAuto ColorMode = iota
+	// ColorAlways always colorizes the output.
+	ColorAlways
+	// ColorNever never colorizes the output.
+	ColorNever
+)
+
+// ConsoleOptions configures the console handler.
+type ConsoleOptions struct {
+	// Level is the minimum level logged (info when nil).
+	Level slog.Leveler
+	// AddSource adds the short source path (`dir/file.go:42`) of the log call.
+	AddSource bool
+	// ReplaceAttr rewrites the attributes before they are printed, as in slog.HandlerOptions.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+	// Color defines when the output is colorized.
+	Color ColorMode
+}
+
+type consoleHandler struct {
+	opts  ConsoleOptions
+	color bool
+	w     io.Writer
+	mu    *sync.Mutex
+
+	// groups are the groups opened with WithGroup, passed to ReplaceAttr.
+	groups []string
+	// prefix qualifies the keys of the attributes in the open groups.
+	prefix string
+	// attrs and blocks hold the attributes added by WithAttrs, already
+	// formatted on a single line or as multi-line blocks.
+	attrs  []byte
+	blocks []byte
+}
+
+// NewConsoleHandler creates a human friendly handler for local development:
+// aligned timestamps, colorized levels, short source paths and indented
+// multi-line blocks for structured values (structs, maps and slices).
+func NewConsoleHandler(w io.Writer, opts *ConsoleOptions) slog.Handler {
+	h := &consoleHandler{w: w, mu: &sync.Mutex{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	switch h.opts.Color {
+	case ColorAlways:
+		h.color = true
+	case ColorAuto:
+		h.color = isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	}
+	return h
+}
+
+// isTerminal reports whether w writes to a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	var blocks []byte
+
+	if !r.Time.IsZero() {
+		if a, ok := h.replaceBuiltin(slog.Time(slog.TimeKey, r.Time)); ok {
+			buf = h.appendColored(buf, ansiDim, consoleValue(a.Value, func(t time.Time) string {
+				return t.Format(consoleTimeFormat)
+			}))
+			buf = append(buf, ' ')
+		}
+	}
+	if a, ok := h.replaceBuiltin(slog.Any(slog.LevelKey, r.Level)); ok {
+		if level, isLevel := a.Value.Any().(slog.Level); isLevel {
+			buf = h.appendColored(buf, levelColor(level), fmt.Sprintf("%-5s", levelLabel(level)))
+		} else if a.Value.String() == levelLabel(r.Level) {
+			buf = h.appendColored(buf, levelColor(r.Level), fmt.Sprintf("%-5s", a.Value.String()))
+		} else {
+			buf = append(buf, a.Value.String()...)
+		}
+		buf = append(buf, ' ')
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		frame, _ := frames.Next()
+		src := &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}
+		if a, ok := h.replaceBuiltin(slog.Any(slog.SourceKey, src)); ok {
+			text := a.Value.String()
+			if s, isSource := a.Value.Any().(*slog.Source); isSource {
+				text = shortSource(s)
+			}
+			buf = h.appendColored(buf, ansiDim, "["+text+"]")
+			buf = append(buf, ' ')
+		}
+	}
+	if a, ok := h.replaceBuiltin(slog.String(slog.MessageKey, r.Message)); ok {
+		buf = h.appendColored(buf, ansiBold, a.Value.String())
+	}
+
+	buf = append(buf, h.attrs...)
+	blocks = append(blocks, h.blocks...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf, blocks = h.appendAttr(buf, blocks, h.groups, h.prefix, a)
+		return true
+	})
+	buf = append(buf, '\n')
+	buf = append(buf, blocks...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append([]byte(nil), h.attrs...)
+	h2.blocks = append([]byte(nil), h.blocks...)
+	for _, a := range attrs {
+		h2.attrs, h2.blocks = h.appendAttr(h2.attrs, h2.blocks, h.groups, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// replaceBuiltin applies ReplaceAttr to a built-in attribute, reporting
+// whether it must still be printed.
+func (h *consoleHandler) replaceBuiltin(a slog.Attr) (slog.Attr, bool) {
+	if h.opts.ReplaceAttr == nil {
+		return a, true
+	}
+	a = h.opts.ReplaceAttr(nil, a)
+	a.Value = a.Value.Resolve()
+	return a, a.Key != ""
+}
+
+func (h *consoleHandler) appendAttr(buf, blocks []byte, groups []string, prefix string, a slog.Attr) ([]byte, []byte) {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return buf, blocks
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return buf, blocks
+		}
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+			prefix += a.Key + "."
+		}
+		for _, ga := range attrs {
+			buf, blocks = h.appendAttr(buf, blocks, groups, prefix, ga)
+		}
+		return buf, blocks
+	}
+
+	key := prefix + a.Key
+	if pretty, ok := prettyValue(a.Value); ok {
+		blocks = append(blocks, "  "...)
+		blocks = h.appendColored(blocks, ansiCyan, key+":")
+		blocks = append(blocks, '\n')
+		for _, line := range strings.Split(pretty, "\n") {
+			blocks = append(blocks, "    "...)
+			blocks = append(blocks, line...)
+			blocks = append(blocks, '\n')
+		}
+		return buf, blocks
+	}
+
+	buf = append(buf, ' ')
+	buf = h.appendColored(buf, ansiCyan, key+"=")
+	text := quoteIfNeeded(consoleValue(a.Value, func(t time.Time) string { return t.Format(time.RFC3339Nano) }))
+	if _, isErr := a.Value.Any().(error); isErr && a.Value.Kind() == slog.KindAny {
+		buf = h.appendColored(buf, ansiRed, text)
+	} else {
+		buf = append(buf, text...)
+	}
+	return buf, blocks
+}
+
+func (h *consoleHandler) appendColored(buf []byte, color, s string) []byte {
+	if !h.color {
+		return append(buf, s...)
+	}
+	buf = append(buf, color...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	default:
+		return ansiMagenta
+	}
+}
+
+// shortSource returns the source file with its parent directory and line.
+func shortSource(s *slog.Source) string {
+	dir, file := filepath.Split(s.File)
+	return filepath.Join(filepath.Base(dir), file) + ":" + strconv.Itoa(s.Line)
+}
+
+func consoleValue(v slog.Value, formatTime func(time.Time) string) string {
+	if v.Kind() == slog.KindTime {
+		return formatTime(v.Time())
+	}
+	return v.String()
+}
+
+// prettyValue returns the indented JSON of structured values (structs, maps,
+// slices and arrays), which are printed as multi-line blocks.
+func prettyValue(v slog.Value) (string, bool) {
+	if v.Kind() != slog.KindAny {
+		return "", false
+	}
+	val := v.Any()
+	switch val.(type) {
+	case nil, error, fmt.Stringer, encoding.TextMarshaler, []byte:
+		return "", false
+	}
+	t := reflect.TypeOf(val)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return "", false
+	}
+	b, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
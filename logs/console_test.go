@@ -0,0 +1,118 @@
+package logs
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/eldius/initial-config-go/http/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseConsoleLine parses an uncolored console line into a map, nesting the
+// dotted keys as groups.
+func parseConsoleLine(t *testing.T, line string) map[string]any {
+	t.Helper()
+	m := map[string]any{}
+	fields := strings.Fields(line)
+	if len(fields) > 1 && len(fields[0]) == len("2006-01-02") && fields[0][0] >= '0' && fields[0][0] <= '9' {
+		ts, err := time.Parse(consoleTimeFormat, fields[0]+" "+fields[1])
+		require.NoError(t, err)
+		m[slog.TimeKey] = ts
+		fields = fields[2:]
+	}
+	m[slog.LevelKey] = fields[0]
+	fields = fields[1:]
+	var msg []string
+	for len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		msg = append(msg, fields[0])
+		fields = fields[1:]
+	}
+	m[slog.MessageKey] = strings.Join(msg, " ")
+	for _, f := range fields {
+		key, value, _ := strings.Cut(f, "=")
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		parts := strings.Split(key, ".")
+		cur := m
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := cur[p].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				cur[p] = next
+			}
+			cur = next
+		}
+		cur[parts[len(parts)-1]] = value
+	}
+	return m
+}
+
+func TestConsoleHandler(t *testing.T) {
+	t.Run("passes the slog handler tests", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := slogtest.TestHandler(NewConsoleHandler(&buf, &ConsoleOptions{Color: ColorNever}), func() []map[string]any {
+			var results []map[string]any
+			for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				results = append(results, parseConsoleLine(t, line))
+			}
+			return results
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("formats records for humans", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := slog.New(NewConsoleHandler(&buf, &ConsoleOptions{AddSource: true, Level: slog.LevelDebug, Color: ColorNever}))
+		l.WithGroup("http").Debug("request done", "status", 200, "path", "/a b", "error", errors.New("boom"))
+
+		line := buf.String()
+		assert.Regexp(t, `^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} DEBUG \[logs/console_test\.go:\d+\] request done `, line)
+		assert.Contains(t, line, `http.status=200 http.path="/a b" http.error=boom`)
+	})
+
+	t.Run("pretty prints structured values", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := slog.New(NewConsoleHandler(&buf, &ConsoleOptions{Color: ColorNever}))
+		l.With("request", logging.HTTPRequestLogRecord{Method: "GET", URL: "/health"}).Info("IncomingHTTPRequestReceived", "count", 1)
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.Contains(t, lines[0], "IncomingHTTPRequestReceived count=1")
+		assert.NotContains(t, lines[0], "request")
+		assert.Equal(t, "  request:", lines[1])
+		assert.Equal(t, "    {", lines[2])
+		assert.Contains(t, buf.String(), `      "method": "GET"`)
+	})
+
+	t.Run("colors levels only when enabled", func(t *testing.T) {
+		var colored, plain bytes.Buffer
+		slog.New(NewConsoleHandler(&colored, &ConsoleOptions{Color: ColorAlways})).Error("failed")
+		slog.New(NewConsoleHandler(&plain, nil)).Error("failed")
+
+		assert.Contains(t, colored.String(), ansiRed+"ERROR"+ansiReset)
+		assert.NotContains(t, plain.String(), "\x1b[")
+	})
+
+	t.Run("respects NO_COLOR", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		h := NewConsoleHandler(&bytes.Buffer{}, nil).(*consoleHandler)
+		assert.False(t, h.color)
+	})
+
+	t.Run("applies ReplaceAttr", func(t *testing.T) {
+		var buf bytes.Buffer
+		h, err := LogHandler(configs.LogFormatConsole, configs.LogLevelDEBUG, &buf, "secret")
+		require.NoError(t, err)
+		slog.New(h).Info("test", "secret", "hidden", "visible", "value")
+
+		assert.Contains(t, buf.String(), "secret=*** visible=value")
+	})
+}
@@ -0,0 +1,106 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+type contextFieldsKey struct{}
+
+// KeyValueData holds log fields indexed by their keys.
+type KeyValueData map[string]any
+
+// contextFields is the immutable set of fields stored in a context. attrs
+// holds the fields in insertion order, ready to be added to records.
+type contextFields struct {
+	attrs  []slog.Attr
+	values KeyValueData
+}
+
+// WithFields returns a context carrying the given fields on top of the ones
+// already stored in ctx (a field replaces a parent field with the same key).
+// Fields are copied on write, so the parent context is never modified and
+// contexts can be shared between goroutines.
+func WithFields(ctx context.Context, fields ...KeyValueData) context.Context {
+	current := fieldsFromContext(ctx)
+	size := len(current.values)
+	for _, d := range fields {
+		size += len(d)
+	}
+	if size == len(current.values) {
+		return ctx
+	}
+
+	merged := contextFields{
+		attrs:  make([]slog.Attr, len(current.attrs), size),
+		values: make(KeyValueData, size),
+	}
+	copy(merged.attrs, current.attrs)
+	maps.Copy(merged.values, current.values)
+	for _, d := range fields {
+		for _, k := range slices.Sorted(maps.Keys(d)) {
+			merged.attrs = setAttr(merged.attrs, slog.Any(k, d[k]))
+			merged.values[k] = d[k]
+		}
+	}
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// Fields returns a copy of the fields stored in ctx.
+func Fields(ctx context.Context) KeyValueData {
+	return maps.Clone(fieldsFromContext(ctx).values)
+}
+
+func fieldsFromContext(ctx context.Context) contextFields {
+	if ctx == nil {
+		return contextFields{}
+	}
+	fields, _ := ctx.Value(contextFieldsKey{}).(contextFields)
+	return fields
+}
+
+func setAttr(attrs []slog.Attr, attr slog.Attr) []slog.Attr {
+	for i := range attrs {
+		if attrs[i].Key == attr.Key {
+			attrs[i] = attr
+			return attrs
+		}
+	}
+	return append(attrs, attr)
+}
+
+type contextHandler struct {
+	h slog.Handler
+}
+
+// NewContextHandler wraps h, adding the fields stored in the context (see
+// WithFields) to every record it handles, including the ones logged with
+// slog.InfoContext and friends.
+func NewContextHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(*contextHandler); ok {
+		return h
+	}
+	return &contextHandler{h: h}
+}
+
+func (c *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return c.h.Enabled(ctx, level)
+}
+
+func (c *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := fieldsFromContext(ctx).attrs; len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return c.h.Handle(ctx, record)
+}
+
+func (c *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h: c.h.WithAttrs(attrs)}
+}
+
+func (c *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h: c.h.WithGroup(name)}
+}
@@ -0,0 +1,113 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var m map[string]any
+		require.NoError(t, dec.Decode(&m))
+		entries = append(entries, m)
+	}
+	return entries
+}
+
+func TestWithFields(t *testing.T) {
+	t.Run("merges fields with the parent ones", func(t *testing.T) {
+		parent := WithFields(context.Background(), KeyValueData{"request_id": "abc", "user": "john"})
+		child := WithFields(parent, KeyValueData{"user": "mary", "step": 2})
+
+		assert.Equal(t, KeyValueData{"request_id": "abc", "user": "john"}, Fields(parent))
+		assert.Equal(t, KeyValueData{"request_id": "abc", "user": "mary", "step": 2}, Fields(child))
+	})
+
+	t.Run("returns the same context without fields", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, ctx, WithFields(ctx))
+		assert.Empty(t, Fields(ctx))
+	})
+
+	t.Run("does not share fields between siblings", func(t *testing.T) {
+		parent := WithFields(context.Background(), KeyValueData{"a": 1})
+
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Go(func() {
+				ctx := WithFields(parent, KeyValueData{"i": i})
+				assert.Equal(t, KeyValueData{"a": 1, "i": i}, Fields(ctx))
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, KeyValueData{"a": 1}, Fields(parent))
+	})
+
+	t.Run("modifying the returned fields does not change the context", func(t *testing.T) {
+		ctx := WithFields(context.Background(), KeyValueData{"a": 1})
+		Fields(ctx)["a"] = 2
+		assert.Equal(t, KeyValueData{"a": 1}, Fields(ctx))
+	})
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+	ctx := WithFields(context.Background(), KeyValueData{"request_id": "abc"})
+
+	l.InfoContext(ctx, "with fields")
+	l.Info("without fields")
+	l.With("pkg", "test").InfoContext(WithFields(ctx, KeyValueData{"step": 1}), "derived")
+
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "abc", entries[0]["request_id"])
+	assert.NotContains(t, entries[1], "request_id")
+	assert.Equal(t, "abc", entries[2]["request_id"])
+	assert.Equal(t, float64(1), entries[2]["step"])
+	assert.Equal(t, "test", entries[2]["pkg"])
+
+	t.Run("is not wrapped twice", func(t *testing.T) {
+		h := NewContextHandler(slog.NewJSONHandler(&buf, nil))
+		assert.Same(t, h, NewContextHandler(h))
+	})
+
+	t.Run("context fields are redacted", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := slog.New(NewContextHandler(NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"token"})))
+		l.InfoContext(WithFields(context.Background(), KeyValueData{"token": "secret"}), "test")
+
+		entries := decodeLogLines(t, &buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "***", entries[0]["token"])
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	ctx := WithFields(context.Background(), KeyValueData{"request_id": "abc"})
+	NewLogger(ctx, KeyValueData{"pkg": "upstream"}).Info("upstream")
+	FromContext(ctx).WithExtraData("extra", true).Info("downstream")
+
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "abc", entries[0]["request_id"])
+	assert.Equal(t, "upstream", entries[0]["pkg"])
+	assert.Equal(t, "abc", entries[1]["request_id"])
+	assert.Equal(t, true, entries[1]["extra"])
+	assert.NotContains(t, entries[1], "pkg")
+}
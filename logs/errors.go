@@ -0,0 +1,221 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorKey is the attribute key of the errors added by Logger.WithError and Err.
+const ErrorKey = "error"
+
+const maxStackDepth = 64
+
+// stackError wraps an error with the stack trace of where it was created.
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string { return e.err.Error() }
+func (e *stackError) Unwrap() error { return e.err }
+
+// StackTrace returns the frames of where the error was created.
+func (e *stackError) StackTrace() []runtime.Frame {
+	frames := runtime.CallersFrames(e.pcs)
+	var stack []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+		if !more {
+			return stack
+		}
+	}
+}
+
+func withStack(err error, skip int) error {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return &stackError{err: err, pcs: pcs[:n]}
+}
+
+// Errorf formats an error like fmt.Errorf (supporting %w), capturing the
+// stack trace of the call.
+func Errorf(format string, args ...any) error {
+	// skip [runtime.Callers, withStack, Errorf]
+	return withStack(fmt.Errorf(format, args...), 3)
+}
+
+// WrapError wraps err with msg (`msg: err`), capturing the stack trace of the
+// call. It returns nil when err is nil.
+func WrapError(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return withStack(fmt.Errorf("%s: %w", msg, err), 3)
+}
+
+// Err returns the error attribute rendering err with its type, the chain of
+// wrapped errors and its stack trace (see ErrorValue).
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, ErrorValue(err))
+}
+
+// ErrorValue returns a slog.LogValuer rendering err as a group with its
+// `message`, `type`, the `chain` of the errors it wraps (following
+// errors.Unwrap and errors.Join) and the `stack` of where it was created, when
+// known. Stack traces come from Errorf and WrapError or from errors with a
+// `StackTrace()` method (like github.com/pkg/errors).
+func ErrorValue(err error) slog.LogValuer {
+	return errorValue{err: err}
+}
+
+type errorValue struct {
+	err error
+}
+
+// ErrorInfo is the message and type of an error of the chain.
+type ErrorInfo struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+func (e errorValue) LogValue() slog.Value {
+	if e.err == nil {
+		return slog.AnyValue(nil)
+	}
+	d := describeError(e.err)
+	attrs := []slog.Attr{
+		slog.String("message", d.message),
+		slog.String("type", d.typ),
+	}
+	if len(d.chain) > 0 {
+		attrs = append(attrs, slog.Any("chain", d.chain))
+	}
+	if len(d.stack) > 0 {
+		attrs = append(attrs, slog.Any("stack", formatStack(d.stack)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type errorDescription struct {
+	message string
+	typ     string
+	chain   []ErrorInfo
+	stack   []runtime.Frame
+}
+
+func describeError(err error) errorDescription {
+	root := unwrapStack(err)
+	d := errorDescription{message: root.Error(), typ: errorTypeName(root)}
+	var walk func(err error, root bool)
+	walk = func(err error, root bool) {
+		if st := stackTrace(err); len(st) > 0 {
+			// the innermost stack is the closest to where the error happened
+			d.stack = st
+		}
+		if s, ok := err.(*stackError); ok {
+			walk(s.err, root)
+			return
+		}
+		if !root {
+			d.chain = append(d.chain, ErrorInfo{Message: err.Error(), Type: errorTypeName(err)})
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			if next := u.Unwrap(); next != nil {
+				walk(next, false)
+			}
+		case interface{ Unwrap() []error }:
+			for _, next := range u.Unwrap() {
+				if next != nil {
+					walk(next, false)
+				}
+			}
+		}
+	}
+	walk(err, true)
+	return d
+}
+
+func unwrapStack(err error) error {
+	for {
+		s, ok := err.(*stackError)
+		if !ok {
+			return err
+		}
+		err = s.err
+	}
+}
+
+func errorTypeName(err error) string {
+	return reflect.TypeOf(err).String()
+}
+
+// stackTrace returns the stack trace of errors with a StackTrace method,
+// returning runtime frames or program counters (like github.com/pkg/errors).
+func stackTrace(err error) []runtime.Frame {
+	if s, ok := err.(interface{ StackTrace() []runtime.Frame }); ok {
+		return s.StackTrace()
+	}
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	out := m.Call(nil)[0]
+	if out.Kind() != reflect.Slice || out.Type().Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	pcs := make([]uintptr, out.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(out.Index(i).Uint())
+	}
+	var stack []runtime.Frame
+	for _, pc := range pcs {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		stack = append(stack, frame)
+	}
+	return stack
+}
+
+func formatStack(stack []runtime.Frame) []string {
+	lines := make([]string, 0, len(stack))
+	for _, f := range stack {
+		lines = append(lines, f.Function+" ("+f.File+":"+strconv.Itoa(f.Line)+")")
+	}
+	return lines
+}
+
+// RecordError records err as an exception event of the span in ctx, with its
+// type, message and stack trace. It does nothing when ctx has no recording span.
+func RecordError(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	d := describeError(err)
+	var attrs []attribute.KeyValue
+	if len(d.chain) > 0 {
+		chain := make([]string, 0, len(d.chain))
+		for _, c := range d.chain {
+			chain = append(chain, c.Type+": "+c.Message)
+		}
+		attrs = append(attrs, attribute.StringSlice("exception.chain", chain))
+	}
+	if len(d.stack) > 0 {
+		var sb strings.Builder
+		for _, f := range d.stack {
+			fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		}
+		attrs = append(attrs, attribute.String("exception.stacktrace", sb.String()))
+	}
+	// the span adds the exception type and message
+	span.RecordError(unwrapStack(err), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,120 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// pcError mimics the errors of github.com/pkg/errors, returning program counters.
+type pcError struct {
+	frames []frame
+}
+
+type frame uintptr
+
+func (e *pcError) Error() string       { return "pc error" }
+func (e *pcError) StackTrace() []frame { return e.frames }
+
+func newPCError() error {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(1, pcs)
+	e := &pcError{}
+	for _, pc := range pcs[:n] {
+		e.frames = append(e.frames, frame(pc))
+	}
+	return e
+}
+
+func logError(t *testing.T, err error) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", Err(err))
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 1)
+	return entries[0]["error"].(map[string]any)
+}
+
+func TestErr(t *testing.T) {
+	t.Run("renders the wrapped chain and stack", func(t *testing.T) {
+		base := errors.New("connection refused")
+		err := fmt.Errorf("loading user: %w", WrapError(base, "querying database"))
+
+		e := logError(t, err)
+		assert.Equal(t, "loading user: querying database: connection refused", e["message"])
+		assert.Equal(t, "*fmt.wrapError", e["type"])
+		assert.Equal(t, []any{
+			map[string]any{"message": "querying database: connection refused", "type": "*fmt.wrapError"},
+			map[string]any{"message": "connection refused", "type": "*errors.errorString"},
+		}, e["chain"])
+		stack := e["stack"].([]any)
+		require.NotEmpty(t, stack)
+		assert.Contains(t, stack[0], "TestErr.func1")
+		assert.Contains(t, stack[0], "errors_test.go")
+	})
+
+	t.Run("renders joined errors", func(t *testing.T) {
+		e := logError(t, errors.Join(errors.New("a"), errors.New("b")))
+		assert.Equal(t, "*errors.joinError", e["type"])
+		assert.Len(t, e["chain"], 2)
+		assert.NotContains(t, e, "stack")
+	})
+
+	t.Run("renders the stack of Errorf", func(t *testing.T) {
+		e := logError(t, Errorf("invalid id %d", 42))
+		assert.Equal(t, "invalid id 42", e["message"])
+		assert.Equal(t, "*errors.errorString", e["type"])
+		assert.NotContains(t, e, "chain")
+		assert.Contains(t, e["stack"].([]any)[0], "TestErr.func3")
+	})
+
+	t.Run("extracts StackTrace methods", func(t *testing.T) {
+		e := logError(t, fmt.Errorf("wrapped: %w", newPCError()))
+		stack := e["stack"].([]any)
+		require.NotEmpty(t, stack)
+		assert.Contains(t, stack[0], "newPCError")
+	})
+
+	t.Run("wraps nil errors to nil", func(t *testing.T) {
+		assert.NoError(t, WrapError(nil, "ignored"))
+	})
+}
+
+func TestRecordError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "operation")
+
+	var buf bytes.Buffer
+	l := &logger{ctx: ctx, logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+	errLogger := l.WithError(WrapError(errors.New("boom"), "handling request"))
+	// only the entries logged at error level record it
+	errLogger.Info("retrying")
+	errLogger.Warn("still failing")
+	errLogger.Error("failed")
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "exception", events[0].Name)
+
+	attrs := map[string]string{}
+	for _, a := range events[0].Attributes {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	assert.Equal(t, "*fmt.wrapError", attrs["exception.type"])
+	assert.Equal(t, "handling request: boom", attrs["exception.message"])
+	assert.Equal(t, `["*errors.errorString: boom"]`, attrs["exception.chain"])
+	assert.Contains(t, attrs["exception.stacktrace"], "TestRecordError")
+}
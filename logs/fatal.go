@@ -0,0 +1,140 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// LevelPanic is the level of the entries logged by Logger.Panic.
+	LevelPanic = slog.LevelError + 4
+	// LevelFatal is the level of the entries logged by Logger.Fatal.
+	LevelFatal = slog.LevelError + 8
+)
+
+const defaultExitTimeout = 5 * time.Second
+
+var (
+	exitMu     sync.Mutex
+	exitFunc   = os.Exit
+	exitHooks  []func(context.Context) error
+	panicHooks []func(context.Context) error
+)
+
+// SetExitFunc replaces the function called by Logger.Fatal to exit the
+// process (os.Exit when f is nil), allowing tests to intercept it.
+func SetExitFunc(f func(code int)) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	if f == nil {
+		f = os.Exit
+	}
+	exitFunc = f
+}
+
+// SetExitHooks defines the functions run by Logger.Fatal before exiting, like
+// telemetry.TelemetryForceFlush and telemetry.TelemetryShutdown (registered by
+// setup.InitSetup). The asynchronous handlers and log files are always closed
+// afterward.
+func SetExitHooks(hooks ...func(context.Context) error) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = hooks
+}
+
+// SetPanicHooks defines the functions run by Logger.Panic before panicking,
+// like telemetry.TelemetryForceFlush (registered by setup.InitSetup). As the
+// panic may be recovered, they should flush the telemetry without shutting
+// it down. The asynchronous handlers are always flushed afterward.
+func SetPanicHooks(hooks ...func(context.Context) error) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	panicHooks = hooks
+}
+
+// levelLabel returns the name of the level, naming the fatal and panic levels.
+func levelLabel(level slog.Level) string {
+	switch level {
+	case LevelFatal:
+		return "FATAL"
+	case LevelPanic:
+		return "PANIC"
+	default:
+		return level.String()
+	}
+}
+
+// terminate records the entry on the span of ctx, ending it, then flushes and
+// shuts down the telemetry, waiting up to the `log.exit_timeout` config.
+func terminate(ctx context.Context, msg string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.IsRecording() {
+		RecordError(ctx, orMessageError(err, msg))
+		span.SetStatus(codes.Error, msg)
+		// the span would not be exported otherwise
+		span.End()
+	}
+
+	exitMu.Lock()
+	hooks := exitHooks
+	exitMu.Unlock()
+	runExitHooks(ctx, hooks, func(ctx context.Context) []error {
+		return []error{CloseAsyncHandlers(ctx), CloseLogFiles()}
+	})
+}
+
+// flushBeforePanic records the entry on the span of ctx and flushes the
+// telemetry, waiting up to the `log.exit_timeout` config. The span is left
+// open and nothing is closed, as the panic may be recovered.
+func flushBeforePanic(ctx context.Context, msg string, err error) {
+	RecordError(ctx, orMessageError(err, msg))
+
+	exitMu.Lock()
+	hooks := panicHooks
+	exitMu.Unlock()
+	runExitHooks(ctx, hooks, func(ctx context.Context) []error {
+		return []error{FlushAsyncHandlers(ctx)}
+	})
+}
+
+// runExitHooks runs the hooks then last, reporting their errors on stderr.
+func runExitHooks(ctx context.Context, hooks []func(context.Context) error, last func(context.Context) []error) {
+	timeout := configs.GetLogExitTimeout()
+	if timeout <= 0 {
+		timeout = defaultExitTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	errs := make([]error, 0, len(hooks)+2)
+	for _, hook := range hooks {
+		errs = append(errs, hook(ctx))
+	}
+	errs = append(errs, last(ctx)...)
+	if err := errors.Join(errs...); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to flush telemetry before terminating: %v\n", err)
+	}
+}
+
+func orMessageError(err error, msg string) error {
+	if err == nil {
+		return errors.New(msg)
+	}
+	return err
+}
+
+func exit(code int) {
+	exitMu.Lock()
+	f := exitFunc
+	exitMu.Unlock()
+	f(code)
+}
@@ -0,0 +1,119 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// interceptExit replaces the exit function and hooks, returning the exit
+// codes and the hooks calls.
+func interceptExit(t *testing.T, hooks ...func(context.Context) error) (*[]int, *[]string) {
+	t.Helper()
+	codes := &[]int{}
+	calls := &[]string{}
+	SetExitFunc(func(code int) { *codes = append(*codes, code) })
+	SetExitHooks(append([]func(context.Context) error{func(context.Context) error {
+		*calls = append(*calls, "exit hook")
+		return nil
+	}}, hooks...)...)
+	SetPanicHooks(func(context.Context) error {
+		*calls = append(*calls, "panic hook")
+		return nil
+	})
+	t.Cleanup(func() {
+		SetExitFunc(nil)
+		SetExitHooks()
+		SetPanicHooks()
+	})
+	return codes, calls
+}
+
+func newTestLogger(ctx context.Context, buf *bytes.Buffer) *logger {
+	return &logger{ctx: ctx, logger: slog.New(NewLogHandler(configs.LogFormatJSON, slog.LevelInfo, buf))}
+}
+
+func TestLoggerFatal(t *testing.T) {
+	t.Run("logs, flushes and exits", func(t *testing.T) {
+		exitCodes, calls := interceptExit(t)
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, _ := tp.Tracer("test").Start(context.Background(), "operation")
+
+		var buf bytes.Buffer
+		newTestLogger(ctx, &buf).WithError(errors.New("disk full")).Fatalf("cannot write %s", "state")
+
+		entries := decodeLogLines(t, &buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "FATAL", entries[0]["level"])
+		assert.Equal(t, "cannot write state", entries[0]["message"])
+		assert.Equal(t, []string{"exit hook"}, *calls)
+		assert.Equal(t, []int{1}, *exitCodes)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1, "the span should be ended to be exported")
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		require.Len(t, spans[0].Events(), 1)
+		assert.Equal(t, "exception", spans[0].Events()[0].Name)
+	})
+
+	t.Run("bounds the flush with the exit timeout", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.LogExitTimeoutKey, "10ms")
+		exitCodes, _ := interceptExit(t, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		start := time.Now()
+		newTestLogger(context.Background(), &bytes.Buffer{}).Fatal("stuck")
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, []int{1}, *exitCodes)
+	})
+}
+
+func TestLoggerPanic(t *testing.T) {
+	exitCodes, calls := interceptExit(t)
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "operation")
+
+	var buf bytes.Buffer
+	l := newTestLogger(ctx, &buf)
+	assert.PanicsWithValue(t, "invalid state 42", func() { l.Panicf("invalid state %d", 42) })
+
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "PANIC", entries[0]["level"])
+	assert.Equal(t, []string{"panic hook"}, *calls)
+	assert.Empty(t, *exitCodes)
+
+	// the panic may be recovered, so the span is left to its owner
+	assert.Empty(t, recorder.Ended())
+	span.End()
+	require.Len(t, recorder.Ended(), 1)
+	assert.Equal(t, codes.Unset, recorder.Ended()[0].Status().Code)
+	require.Len(t, recorder.Ended()[0].Events(), 1)
+	assert.Equal(t, "exception", recorder.Ended()[0].Events()[0].Name)
+}
+
+func TestLevelLabels(t *testing.T) {
+	assert.Equal(t, "fatal", LevelName(LevelFatal))
+	assert.Equal(t, "panic", LevelName(LevelPanic))
+	assert.Equal(t, "ALERT", gcpSeverity(LevelFatal))
+
+	var buf bytes.Buffer
+	slog.New(NewConsoleHandler(&buf, &ConsoleOptions{Color: ColorNever})).Log(context.Background(), LevelFatal, "stopping")
+	assert.Contains(t, buf.String(), " FATAL stopping")
+}
@@ -15,28 +15,38 @@ import (
 var logFiles []*os.File
 
 func LogHandler(format, level string, w io.Writer, keysToRedact ...string) (slog.Handler, error) {
-	if strings.ToLower(format) == configs.LogFormatJSON {
-		handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
-			AddSource:   true,
-			Level:       parseLogLevel(level),
-			ReplaceAttr: LogAttrsReplacerFunc(),
-		})
-		if len(keysToRedact) == 0 {
-			return handler, nil
-		}
-		return NewRedactHandler(handler, keysToRedact), nil
-	}
-	handler := slog.NewTextHandler(w, &slog.HandlerOptions{
-		AddSource:   true,
-		Level:       parseLogLevel(level),
-		ReplaceAttr: LogAttrsReplacerFunc(),
-	})
+	handler := NewLogHandler(format, parseLogLevel(level), w)
 	if len(keysToRedact) == 0 {
 		return handler, nil
 	}
 	return NewRedactHandler(handler, keysToRedact), nil
 }
 
+// NewLogHandler creates the JSON, console, ECS, GCP, logfmt or text handler writing to w,
+// enabling the records at or above level (which may change at runtime, see LevelRouter.MinLevel).
+func NewLogHandler(format string, level slog.Leveler, w io.Writer) slog.Handler {
+	if h := schemaHandler(format, level, w); h != nil {
+		return h
+	}
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: LogAttrsReplacerFunc(),
+	}
+	switch strings.ToLower(format) {
+	case configs.LogFormatJSON:
+		return slog.NewJSONHandler(w, opts)
+	case configs.LogFormatConsole:
+		return NewConsoleHandler(w, &ConsoleOptions{
+			Level:       opts.Level,
+			AddSource:   opts.AddSource,
+			ReplaceAttr: opts.ReplaceAttr,
+		})
+	default:
+		return slog.NewTextHandler(w, opts)
+	}
+}
+
 func parseLogLevel(lvl string) slog.Level {
 	switch strings.ToLower(lvl) {
 	case configs.LogLevelDEBUG:
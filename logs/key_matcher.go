@@ -0,0 +1,149 @@
+package logs
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key match modes. A redacted key may be prefixed by its mode
+// (e.g. `exact:token`); keys without a prefix use substring matching.
+const (
+	MatchModeSubstring = "substring"
+	MatchModeExact     = "exact"
+	MatchModePrefix    = "prefix"
+	MatchModeGlob      = "glob"
+	MatchModePath      = "path"
+)
+
+type keyMatcher struct {
+	mode    string
+	pattern string
+}
+
+// parseKeyMatcher parses a redacted key definition such as `password`,
+// `exact:key`, `prefix:x_`, `glob:*_token` or `path:request.headers.authorization`.
+func parseKeyMatcher(def string) (keyMatcher, bool) {
+	def = strings.ToLower(strings.TrimSpace(def))
+	mode, pattern, found := strings.Cut(def, ":")
+	if !found {
+		return keyMatcher{mode: MatchModeSubstring, pattern: def}, def != ""
+	}
+	switch mode {
+	case MatchModeGlob, MatchModePath:
+		if _, err := path.Match(pattern, ""); err != nil {
+			// malformed pattern, prefer over-redacting to leaking
+			return keyMatcher{mode: MatchModeSubstring, pattern: pattern}, pattern != ""
+		}
+		return keyMatcher{mode: mode, pattern: pattern}, pattern != ""
+	case MatchModeSubstring, MatchModeExact, MatchModePrefix:
+		return keyMatcher{mode: mode, pattern: pattern}, pattern != ""
+	default:
+		// not a known mode, the colon is part of the key
+		return keyMatcher{mode: MatchModeSubstring, pattern: def}, true
+	}
+}
+
+// keyMatchers groups the parsed matchers by mode, so keys can be checked
+// without allocating (only glob and path modes need a lowered copy).
+type keyMatchers struct {
+	exact      []string
+	prefixes   []string
+	substrings []string
+	globs      []string
+	paths      []string
+}
+
+func (k *keyMatchers) add(m keyMatcher) {
+	switch m.mode {
+	case MatchModeExact:
+		k.exact = append(k.exact, m.pattern)
+	case MatchModePrefix:
+		k.prefixes = append(k.prefixes, m.pattern)
+	case MatchModeGlob:
+		k.globs = append(k.globs, m.pattern)
+	case MatchModePath:
+		k.paths = append(k.paths, m.pattern)
+	default:
+		k.substrings = append(k.substrings, m.pattern)
+	}
+}
+
+func (k *keyMatchers) empty() bool {
+	return len(k.exact) == 0 && len(k.prefixes) == 0 && len(k.substrings) == 0 && len(k.globs) == 0 && len(k.paths) == 0
+}
+
+// matchKey checks the key alone against the exact, prefix, substring and glob matchers.
+func (k *keyMatchers) matchKey(key string) bool {
+	for _, e := range k.exact {
+		if strings.EqualFold(key, e) {
+			return true
+		}
+	}
+	for _, p := range k.prefixes {
+		if hasPrefixFold(key, p) {
+			return true
+		}
+	}
+	for _, s := range k.substrings {
+		if containsFold(key, s) {
+			return true
+		}
+	}
+	if len(k.globs) > 0 {
+		lowerKey := strings.ToLower(key)
+		for _, g := range k.globs {
+			if ok, _ := path.Match(g, lowerKey); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPath checks the dotted path of key against the path matchers.
+func (k *keyMatchers) matchPath(parents []string, key string) bool {
+	if len(k.paths) == 0 {
+		return false
+	}
+	fullPath := strings.ToLower(key)
+	if len(parents) > 0 {
+		fullPath = strings.ToLower(strings.Join(parents, ".") + "." + key)
+	}
+	for _, p := range k.paths {
+		if ok, _ := path.Match(p, fullPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixFold is a case-insensitive strings.HasPrefix for a lowered prefix.
+func hasPrefixFold(s, prefix string) bool {
+	if !isASCII(s) {
+		return strings.HasPrefix(strings.ToLower(s), prefix)
+	}
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// containsFold is a case-insensitive strings.Contains for a lowered substring.
+func containsFold(s, substr string) bool {
+	if !isASCII(s) {
+		return strings.Contains(strings.ToLower(s), substr)
+	}
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor_MatchModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		parents []string
+		target  string
+		want    bool
+	}{
+		{"substring is the default", "key", nil, "monkey_count", true},
+		{"exact matches the whole key", "exact:key", nil, "key", true},
+		{"exact ignores case", "exact:Key", nil, "KEY", true},
+		{"exact does not match substrings", "exact:key", nil, "monkey_count", false},
+		{"prefix matches", "prefix:x-secret", nil, "X-Secret-Token", true},
+		{"prefix does not match suffixes", "prefix:secret", nil, "x-secret", false},
+		{"glob matches", "glob:*_token", nil, "refresh_token", true},
+		{"glob does not match", "glob:*_token", nil, "token_type", false},
+		{"path matches nested keys", "path:request.headers.authorization", []string{"request", "headers"}, "Authorization", true},
+		{"path matches dotted keys", "path:request.headers.authorization", nil, "request.headers.authorization", true},
+		{"path does not match other parents", "path:request.headers.authorization", []string{"response", "headers"}, "Authorization", false},
+		{"path supports wildcards", "path:*.headers.authorization", []string{"response", "headers"}, "Authorization", true},
+		{"unknown mode is part of the key", "x:y", nil, "header_x:y", true},
+		{"malformed glob falls back to substring", "glob:[abc", nil, "my_[abc_key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewRedactor([]string{tt.key}).ShouldRedactPath(tt.parents, tt.target))
+		})
+	}
+}
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"pwd"`
+	Pin      string `redact:"true"`
+	Headers  map[string][]string
+}
+
+func TestRedactHandler_Paths(t *testing.T) {
+	logAndDecode := func(t *testing.T, keys []string, log func(l *slog.Logger)) map[string]any {
+		t.Helper()
+		var buf bytes.Buffer
+		log(slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), keys)))
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		return m
+	}
+
+	t.Run("redacts only the targeted nested field", func(t *testing.T) {
+		m := logAndDecode(t, []string{"path:request.headers.authorization"}, func(l *slog.Logger) {
+			l.Info("test",
+				"request", map[string]any{"headers": map[string]any{"Authorization": "secret"}},
+				"response", map[string]any{"headers": map[string]any{"Authorization": "visible"}},
+			)
+		})
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "request", "headers", "Authorization"))
+		assert.Equal(t, "visible", getLogEntryAttrValue(t, m, "response", "headers", "Authorization"))
+	})
+
+	t.Run("follows slog groups", func(t *testing.T) {
+		m := logAndDecode(t, []string{"path:http.request.token"}, func(l *slog.Logger) {
+			l.WithGroup("http").Info("test",
+				slog.Group("request", slog.String("token", "secret")),
+				slog.Group("response", slog.String("token", "visible")),
+			)
+		})
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "http", "request", "token"))
+		assert.Equal(t, "visible", getLogEntryAttrValue(t, m, "http", "response", "token"))
+	})
+
+	t.Run("uses json tags and struct field names in paths", func(t *testing.T) {
+		m := logAndDecode(t, []string{"path:login.pwd", "path:login.headers.x-token"}, func(l *slog.Logger) {
+			l.Info("test", "login", credentials{
+				User:     "admin",
+				Password: "secret",
+				Headers:  map[string][]string{"X-Token": {"abc"}, "Accept": {"*/*"}},
+			})
+		})
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "login", "pwd"))
+		assert.Equal(t, "admin", getLogEntryAttrValue(t, m, "login", "user"))
+		assert.Equal(t, []any{"***"}, getLogEntryAttrValue(t, m, "login", "Headers", "X-Token"))
+		assert.Equal(t, []any{"*/*"}, getLogEntryAttrValue(t, m, "login", "Headers", "Accept"))
+	})
+
+	t.Run("honors the redact struct tag", func(t *testing.T) {
+		m := logAndDecode(t, []string{"exact:unused"}, func(l *slog.Logger) {
+			l.Info("test", "login", credentials{User: "admin", Pin: "1234"})
+		})
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "login", "Pin"))
+		assert.Equal(t, "admin", getLogEntryAttrValue(t, m, "login", "user"))
+	})
+
+	t.Run("exact mode avoids over-redaction", func(t *testing.T) {
+		m := logAndDecode(t, []string{"exact:key"}, func(l *slog.Logger) {
+			l.Info("test", "key", "secret", "monkey_count", 3)
+		})
+		assert.Equal(t, "***", m["key"])
+		assert.Equal(t, float64(3), m["monkey_count"])
+	})
+}
@@ -0,0 +1,369 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoggerNameKey is the attribute holding the name of a named logger.
+const LoggerNameKey = "pkg"
+
+var defaultLevelRouter = NewLevelRouter(slog.LevelInfo, nil)
+
+// LevelRouter holds the default log level and the level overrides of named
+// loggers. Its levels can be changed at runtime and are safe for concurrent use.
+type LevelRouter struct {
+	mu    sync.Mutex
+	state atomic.Pointer[levelState]
+	// minLevel is the lowest level enabled for any logger.
+	minLevel slog.LevelVar
+	// timers holds the pending reverts of temporary levels, indexed by logger
+	// name (the default level uses an empty name).
+	timers map[string]*levelTimer
+}
+
+type levelState struct {
+	defaultLevel slog.Level
+	levels       map[string]slog.Level
+}
+
+type levelTimer struct {
+	timer   *time.Timer
+	expires time.Time
+	// restore reverts the level changed by the temporary level.
+	restore func(defaultLevel *slog.Level, levels map[string]slog.Level)
+}
+
+// NewLevelRouter creates a LevelRouter using defaultLevel for the loggers
+// without an override.
+func NewLevelRouter(defaultLevel slog.Level, levels map[string]slog.Level) *LevelRouter {
+	r := &LevelRouter{timers: map[string]*levelTimer{}}
+	r.store(defaultLevel, nil)
+	r.SetLevels(levels)
+	return r
+}
+
+// DefaultLevelRouter returns the LevelRouter used by the handler installed by
+// the setup package and by the package level functions.
+func DefaultLevelRouter() *LevelRouter {
+	return defaultLevelRouter
+}
+
+func (r *LevelRouter) store(defaultLevel slog.Level, levels map[string]slog.Level) {
+	if levels == nil {
+		levels = map[string]slog.Level{}
+	}
+	minLevel := defaultLevel
+	for _, l := range levels {
+		minLevel = min(minLevel, l)
+	}
+	r.state.Store(&levelState{defaultLevel: defaultLevel, levels: levels})
+	r.minLevel.Set(minLevel)
+}
+
+// update applies fn to a copy of the current levels.
+func (r *LevelRouter) update(fn func(defaultLevel *slog.Level, levels map[string]slog.Level)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updateLocked(fn)
+}
+
+func (r *LevelRouter) updateLocked(fn func(defaultLevel *slog.Level, levels map[string]slog.Level)) {
+	current := r.state.Load()
+	defaultLevel, levels := current.defaultLevel, maps.Clone(current.levels)
+	fn(&defaultLevel, levels)
+	r.store(defaultLevel, levels)
+}
+
+// stopTimerLocked cancels the pending revert of the named logger, returning it
+// when there was one.
+func (r *LevelRouter) stopTimerLocked(name string) *levelTimer {
+	t, ok := r.timers[name]
+	if !ok {
+		return nil
+	}
+	t.timer.Stop()
+	delete(r.timers, name)
+	return t
+}
+
+// stopOverrideTimersLocked cancels the pending reverts of all the overrides,
+// and of the default level when includeDefault is true.
+func (r *LevelRouter) stopOverrideTimersLocked(includeDefault bool) {
+	for name := range r.timers {
+		if name != "" || includeDefault {
+			r.stopTimerLocked(name)
+		}
+	}
+}
+
+// SetDefaultLevel changes the level of the loggers without an override.
+func (r *LevelRouter) SetDefaultLevel(level slog.Level) {
+	r.update(func(defaultLevel *slog.Level, _ map[string]slog.Level) {
+		r.stopTimerLocked("")
+		*defaultLevel = level
+	})
+}
+
+// SetLevel overrides the level of the named logger.
+func (r *LevelRouter) SetLevel(name string, level slog.Level) {
+	name = strings.ToLower(name)
+	r.update(func(_ *slog.Level, levels map[string]slog.Level) {
+		r.stopTimerLocked(name)
+		levels[name] = level
+	})
+}
+
+// SetLevels replaces all the level overrides.
+func (r *LevelRouter) SetLevels(levels map[string]slog.Level) {
+	r.update(func(_ *slog.Level, current map[string]slog.Level) {
+		r.stopOverrideTimersLocked(false)
+		clear(current)
+		for name, level := range levels {
+			current[strings.ToLower(name)] = level
+		}
+	})
+}
+
+// ResetLevel removes the override of the named logger.
+func (r *LevelRouter) ResetLevel(name string) {
+	name = strings.ToLower(name)
+	r.update(func(_ *slog.Level, levels map[string]slog.Level) {
+		r.stopTimerLocked(name)
+		delete(levels, name)
+	})
+}
+
+// SetDefaultLevelFor changes the default level for the given duration, then
+// reverts it to its previous value.
+func (r *LevelRouter) SetDefaultLevelFor(level slog.Level, d time.Duration) {
+	r.setTemporary("", d, func(defaultLevel *slog.Level, _ map[string]slog.Level) func(*slog.Level, map[string]slog.Level) {
+		previous := *defaultLevel
+		*defaultLevel = level
+		return func(defaultLevel *slog.Level, _ map[string]slog.Level) {
+			*defaultLevel = previous
+		}
+	})
+}
+
+// SetLevelFor overrides the level of the named logger for the given duration,
+// then reverts it to its previous level (or removes the override).
+func (r *LevelRouter) SetLevelFor(name string, level slog.Level, d time.Duration) {
+	name = strings.ToLower(name)
+	r.setTemporary(name, d, func(_ *slog.Level, levels map[string]slog.Level) func(*slog.Level, map[string]slog.Level) {
+		previous, hadPrevious := levels[name]
+		levels[name] = level
+		return func(_ *slog.Level, levels map[string]slog.Level) {
+			if hadPrevious {
+				levels[name] = previous
+			} else {
+				delete(levels, name)
+			}
+		}
+	})
+}
+
+// setTemporary applies set, which returns the function reverting it, and
+// schedules the revert after d. Chained temporary levels revert to the level
+// set before the first one.
+func (r *LevelRouter) setTemporary(name string, d time.Duration, set func(*slog.Level, map[string]slog.Level) func(*slog.Level, map[string]slog.Level)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := &levelTimer{expires: time.Now().Add(d)}
+	if previous := r.stopTimerLocked(name); previous != nil {
+		// revert the running temporary level first, so its original value is restored
+		r.updateLocked(previous.restore)
+	}
+	r.updateLocked(func(defaultLevel *slog.Level, levels map[string]slog.Level) {
+		t.restore = set(defaultLevel, levels)
+	})
+	t.timer = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.timers[name] != t {
+			return
+		}
+		delete(r.timers, name)
+		r.updateLocked(t.restore)
+	})
+	r.timers[name] = t
+}
+
+// Expirations returns when the temporary levels revert, indexed by logger
+// name (the default level uses an empty name).
+func (r *LevelRouter) Expirations() map[string]time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expirations := make(map[string]time.Time, len(r.timers))
+	for name, t := range r.timers {
+		expirations[name] = t.expires
+	}
+	return expirations
+}
+
+// DefaultLevel returns the level of the loggers without an override.
+func (r *LevelRouter) DefaultLevel() slog.Level {
+	return r.state.Load().defaultLevel
+}
+
+// Levels returns a copy of the level overrides.
+func (r *LevelRouter) Levels() map[string]slog.Level {
+	return maps.Clone(r.state.Load().levels)
+}
+
+// Level returns the level of the named logger. An override applies to the
+// logger with the same name and to its children, whose names start with the
+// override name followed by `.`, `_` or `/` (`http` applies to `http_client`);
+// the most specific override wins.
+func (r *LevelRouter) Level(name string) slog.Level {
+	state := r.state.Load()
+	if name == "" || len(state.levels) == 0 {
+		return state.defaultLevel
+	}
+	name = strings.ToLower(name)
+	if l, ok := state.levels[name]; ok {
+		return l
+	}
+	level, matched := state.defaultLevel, 0
+	for n, l := range state.levels {
+		if len(n) > matched && len(name) > len(n) && strings.HasPrefix(name, n) && isNameSeparator(name[len(n)]) {
+			level, matched = l, len(n)
+		}
+	}
+	return level
+}
+
+// LevelName returns the lowercase name of the level, like `debug` or `fatal`.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(levelLabel(level))
+}
+
+func isNameSeparator(c byte) bool {
+	return c == '.' || c == '_' || c == '/'
+}
+
+// MinLevel returns the lowest level enabled for any logger, updated as the
+// levels change. It is meant to be the level of the handler wrapped by
+// NewLevelRouterHandler.
+func (r *LevelRouter) MinLevel() *slog.LevelVar {
+	return &r.minLevel
+}
+
+// ConfigureLevels sets the default level and replaces the level overrides of
+// the default LevelRouter from their names (debug, info, warn or error).
+func ConfigureLevels(defaultLevel string, levels map[string]string) {
+	defaultLevelRouter.update(func(d *slog.Level, current map[string]slog.Level) {
+		defaultLevelRouter.stopOverrideTimersLocked(true)
+		*d = parseLogLevel(defaultLevel)
+		clear(current)
+		for name, level := range levels {
+			current[strings.ToLower(name)] = parseLogLevel(level)
+		}
+	})
+}
+
+// SetLevel overrides the level of the named logger in the default LevelRouter.
+func SetLevel(name string, level slog.Level) {
+	defaultLevelRouter.SetLevel(name, level)
+}
+
+// ResetLevel removes the override of the named logger in the default LevelRouter.
+func ResetLevel(name string) {
+	defaultLevelRouter.ResetLevel(name)
+}
+
+// SetDefaultLevel changes the default level of the default LevelRouter.
+func SetDefaultLevel(level slog.Level) {
+	defaultLevelRouter.SetDefaultLevel(level)
+}
+
+// Named returns a Logger tagged with the given name (in the `pkg` attribute),
+// whose level can be overridden with the `log.levels` config or SetLevel.
+func Named(name string) Logger {
+	return NewLogger(context.Background(), KeyValueData{LoggerNameKey: name})
+}
+
+type levelRouterHandler struct {
+	h      slog.Handler
+	router *LevelRouter
+	name   string
+	groups int
+}
+
+// NewLevelRouterHandler wraps h, filtering records using the level of the
+// logger named by their `pkg` attribute. The wrapped handler must enable the
+// lowest level of the router (see LevelRouter.MinLevel).
+func NewLevelRouterHandler(h slog.Handler, router *LevelRouter) slog.Handler {
+	return &levelRouterHandler{
+		h:      h,
+		router: router,
+	}
+}
+
+func (l *levelRouterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if l.name != "" {
+		return level >= l.router.Level(l.name) && l.h.Enabled(ctx, level)
+	}
+	// the record may still name its logger, so it is filtered by Handle
+	return level >= l.router.minLevel.Level() && l.h.Enabled(ctx, level)
+}
+
+func (l *levelRouterHandler) Handle(ctx context.Context, record slog.Record) error {
+	name := l.name
+	if name == "" {
+		name = recordLoggerName(ctx, record, l.groups == 0)
+	}
+	if record.Level < l.router.Level(name) {
+		return nil
+	}
+	return l.h.Handle(ctx, record)
+}
+
+func (l *levelRouterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	name := l.name
+	if l.groups == 0 {
+		for _, a := range attrs {
+			if a.Key == LoggerNameKey {
+				name = a.Value.String()
+			}
+		}
+	}
+	return &levelRouterHandler{h: l.h.WithAttrs(attrs), router: l.router, name: name, groups: l.groups}
+}
+
+func (l *levelRouterHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return l
+	}
+	return &levelRouterHandler{h: l.h.WithGroup(name), router: l.router, name: l.name, groups: l.groups + 1}
+}
+
+// recordLoggerName returns the logger name set in the record (unless its
+// attributes are nested in a group) or in the context fields.
+func recordLoggerName(ctx context.Context, record slog.Record, topLevel bool) string {
+	var name string
+	if topLevel {
+		record.Attrs(func(a slog.Attr) bool {
+			if a.Key == LoggerNameKey {
+				name = a.Value.String()
+				return false
+			}
+			return true
+		})
+	}
+	if name != "" {
+		return name
+	}
+	if v, ok := fieldsFromContext(ctx).values[LoggerNameKey]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
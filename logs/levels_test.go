@@ -0,0 +1,169 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelRouter_Level(t *testing.T) {
+	r := NewLevelRouter(slog.LevelInfo, map[string]slog.Level{
+		"http":        slog.LevelWarn,
+		"http_client": slog.LevelDebug,
+		"Telemetry":   slog.LevelError,
+	})
+
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"", slog.LevelInfo},
+		{"database", slog.LevelInfo},
+		{"http", slog.LevelWarn},
+		{"http_server_logging", slog.LevelWarn},
+		{"http_client", slog.LevelDebug},
+		{"http_client_logging", slog.LevelDebug},
+		{"httpx", slog.LevelInfo},
+		{"TELEMETRY", slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Level(tt.name))
+		})
+	}
+
+	t.Run("min level is the lowest enabled level", func(t *testing.T) {
+		assert.Equal(t, slog.LevelDebug, r.MinLevel().Level())
+	})
+}
+
+func TestLevelRouter_RuntimeChanges(t *testing.T) {
+	r := NewLevelRouter(slog.LevelInfo, nil)
+	assert.Equal(t, slog.LevelInfo, r.MinLevel().Level())
+
+	r.SetLevel("HTTP_Client", slog.LevelDebug)
+	assert.Equal(t, slog.LevelDebug, r.Level("http_client"))
+	assert.Equal(t, slog.LevelDebug, r.MinLevel().Level())
+
+	r.SetDefaultLevel(slog.LevelWarn)
+	assert.Equal(t, slog.LevelWarn, r.Level("other"))
+
+	r.ResetLevel("http_client")
+	assert.Equal(t, slog.LevelWarn, r.Level("http_client"))
+	assert.Equal(t, slog.LevelWarn, r.MinLevel().Level())
+
+	r.SetLevels(map[string]slog.Level{"a": slog.LevelError})
+	assert.Equal(t, map[string]slog.Level{"a": slog.LevelError}, r.Levels())
+
+	levels := r.Levels()
+	levels["b"] = slog.LevelDebug
+	assert.Equal(t, slog.LevelWarn, r.Level("b"), "returned levels must be a copy")
+}
+
+func TestLevelRouterHandler(t *testing.T) {
+	newLogger := func() (*slog.Logger, *LevelRouter, *bytes.Buffer) {
+		var buf bytes.Buffer
+		router := NewLevelRouter(slog.LevelInfo, map[string]slog.Level{"http_client": slog.LevelDebug, "telemetry": slog.LevelWarn})
+		h := NewLevelRouterHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: router.MinLevel()}), router)
+		return slog.New(NewContextHandler(h)), router, &buf
+	}
+
+	t.Run("filters records using the logger name", func(t *testing.T) {
+		l, _, buf := newLogger()
+		l.Debug("default debug")
+		l.With(LoggerNameKey, "http_client").Debug("client debug")
+		l.With(LoggerNameKey, "telemetry").Info("telemetry info")
+		l.With(LoggerNameKey, "telemetry").Warn("telemetry warn")
+		l.Debug("record attr debug", LoggerNameKey, "http_client")
+		l.DebugContext(WithFields(context.Background(), KeyValueData{LoggerNameKey: "http_client"}), "context debug")
+
+		entries := decodeLogLines(t, buf)
+		var messages []string
+		for _, e := range entries {
+			messages = append(messages, e["msg"].(string))
+		}
+		assert.Equal(t, []string{"client debug", "telemetry warn", "record attr debug", "context debug"}, messages)
+	})
+
+	t.Run("ignores names nested in groups", func(t *testing.T) {
+		l, _, buf := newLogger()
+		l.WithGroup("request").With(LoggerNameKey, "http_client").Debug("nested")
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("applies runtime changes", func(t *testing.T) {
+		l, router, buf := newLogger()
+		named := l.With(LoggerNameKey, "database")
+		named.Debug("before")
+		router.SetLevel("database", slog.LevelDebug)
+		named.Debug("after")
+
+		entries := decodeLogLines(t, buf)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "after", entries[0]["msg"])
+	})
+}
+
+func TestNamed(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(NewLevelRouterHandler(
+		slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: DefaultLevelRouter().MinLevel()}),
+		DefaultLevelRouter(),
+	)))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		ConfigureLevels("info", nil)
+	})
+
+	ConfigureLevels("info", map[string]string{"http_client": "debug"})
+	Named("http_client").Debug("client debug")
+	Named("database").Debug("database debug")
+	SetLevel("database", slog.LevelDebug)
+	Named("database").Debug("database debug enabled")
+
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "client debug", entries[0]["msg"])
+	assert.Equal(t, "http_client", entries[0][LoggerNameKey])
+	assert.Equal(t, "database debug enabled", entries[1]["msg"])
+}
+
+func TestLevelRouter_TemporaryLevels(t *testing.T) {
+	t.Run("reverts overrides once elapsed", func(t *testing.T) {
+		r := NewLevelRouter(slog.LevelInfo, map[string]slog.Level{"http_client": slog.LevelWarn})
+		r.SetLevelFor("http_client", slog.LevelDebug, 20*time.Millisecond)
+		r.SetLevelFor("database", slog.LevelDebug, 20*time.Millisecond)
+		assert.Equal(t, slog.LevelDebug, r.Level("http_client"))
+		assert.Len(t, r.Expirations(), 2)
+
+		assert.Eventually(t, func() bool { return len(r.Expirations()) == 0 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, map[string]slog.Level{"http_client": slog.LevelWarn}, r.Levels())
+		assert.Equal(t, slog.LevelInfo, r.MinLevel().Level())
+	})
+
+	t.Run("chained temporary levels revert to the original level", func(t *testing.T) {
+		r := NewLevelRouter(slog.LevelInfo, nil)
+		r.SetDefaultLevelFor(slog.LevelWarn, time.Hour)
+		r.SetDefaultLevelFor(slog.LevelDebug, 20*time.Millisecond)
+		assert.Equal(t, slog.LevelDebug, r.DefaultLevel())
+
+		assert.Eventually(t, func() bool { return r.DefaultLevel() == slog.LevelInfo }, time.Second, 5*time.Millisecond)
+		assert.Empty(t, r.Expirations())
+	})
+
+	t.Run("permanent changes cancel the revert", func(t *testing.T) {
+		r := NewLevelRouter(slog.LevelInfo, nil)
+		r.SetLevelFor("database", slog.LevelDebug, 20*time.Millisecond)
+		r.SetLevel("database", slog.LevelError)
+		assert.Empty(t, r.Expirations())
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, slog.LevelError, r.Level("database"))
+	})
+}
@@ -4,15 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"maps"
 	"runtime"
 	"time"
 )
 
-const (
-	key contextDataKey = "logger"
-)
-
 var (
 	_ Logger = (*logger)(nil)
 )
@@ -26,44 +21,41 @@ type Logger interface {
 	Info(msg string)
 	Warn(msg string)
 	Error(msg string)
+	// Fatal logs at LevelFatal, records the entry on the span of the context,
+	// flushes the telemetry and exits with status 1 (see SetExitFunc).
+	Fatal(msg string)
+	Fatalf(format string, args ...any)
+	// Panic logs at LevelPanic, records the entry on the span of the context
+	// (leaving it open), flushes the telemetry and panics with the message.
+	Panic(msg string)
+	Panicf(format string, args ...any)
 
 	WithError(err error) Logger
 	WithExtraData(key string, value any) Logger
 	WithExtraDataMap(data map[string]any) Logger
 }
 
-type contextDataKey string
-
 type logger struct {
 	ctx    context.Context
 	logger *slog.Logger
+	// err is the error added by WithError, recorded on the span of ctx.
+	err error
 }
 
-type KeyValueData map[string]any
-
-func addDataToContext(ctx context.Context, data ...KeyValueData) context.Context {
-	if values := ctx.Value(key); values != nil {
-		if m, ok := values.(KeyValueData); ok {
-			for _, d := range data {
-				maps.Copy(m, d)
-			}
-			return context.WithValue(ctx, contextDataKey("logger"), m)
-		}
-	}
-	return context.WithValue(ctx, contextDataKey("logger"), data)
+// NewLogger creates a new Logger instance logging the fields stored in ctx
+// along with the given ones
+func NewLogger(ctx context.Context, fields ...KeyValueData) Logger {
+	return FromContext(WithFields(ctx, fields...))
 }
 
-// NewLogger creates a new Logger instance
-func NewLogger(ctx context.Context, fields ...KeyValueData) Logger {
-	l := slog.Default()
-	ctx = addDataToContext(ctx, fields...)
-	for _, d := range fields {
-		for k, v := range d {
-			l = l.With(k, v)
-		}
+// FromContext returns a Logger using the default slog logger that adds the
+// fields stored in ctx (see WithFields) to every entry
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		ctx = context.Background()
 	}
 	return &logger{
-		logger: l,
+		logger: slog.New(NewContextHandler(slog.Default().Handler())),
 		ctx:    ctx,
 	}
 }
@@ -79,6 +71,10 @@ func (l *logger) log(level slog.Level, msg string) {
 	pc = pcs[0]
 	r := slog.NewRecord(time.Now(), level, msg, pc)
 	_ = l.logger.Handler().Handle(l.ctx, r)
+	if level >= slog.LevelError && level < LevelPanic {
+		// Fatal and Panic record the error when terminating
+		RecordError(l.ctx, l.err)
+	}
 }
 
 func (l *logger) Debugf(format string, args ...any) {
@@ -107,10 +103,42 @@ func (l *logger) Error(msg string) {
 	l.log(slog.LevelError, msg)
 }
 
+// Fatal logs msg at LevelFatal, records it on the span of the context, ending
+// it, then flushes and shuts down the telemetry before exiting with status 1.
+func (l *logger) Fatal(msg string) {
+	l.log(LevelFatal, msg)
+	terminate(l.ctx, msg, l.err)
+	exit(1)
+}
+
+// Fatalf is Fatal with a formatted message.
+func (l *logger) Fatalf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	l.log(LevelFatal, msg)
+	terminate(l.ctx, msg, l.err)
+	exit(1)
+}
+
+func (l *logger) Panic(msg string) {
+	l.log(LevelPanic, msg)
+	flushBeforePanic(l.ctx, msg, l.err)
+	panic(msg)
+}
+func (l *logger) Panicf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	l.log(LevelPanic, msg)
+	flushBeforePanic(l.ctx, msg, l.err)
+	panic(msg)
+}
+
+// WithError adds err to the entries, rendered with its chain and stack trace
+// (see Err), and records it as an exception event of the span in the context
+// for the entries logged at error level or above.
 func (l *logger) WithError(err error) Logger {
 	return &logger{
 		ctx:    l.ctx,
-		logger: l.logger.With("error", err),
+		logger: l.logger.With(Err(err)),
+		err:    err,
 	}
 }
 
@@ -118,6 +146,7 @@ func (l *logger) WithExtraData(key string, value any) Logger {
 	return &logger{
 		ctx:    l.ctx,
 		logger: l.logger.With(key, value),
+		err:    l.err,
 	}
 }
 
@@ -129,5 +158,6 @@ func (l *logger) WithExtraDataMap(data map[string]any) Logger {
 	return &logger{
 		ctx:    l.ctx,
 		logger: log,
+		err:    l.err,
 	}
 }
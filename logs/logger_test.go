@@ -78,5 +78,5 @@ func TestLoggerSource_WithError(t *testing.T) {
 	assert.Contains(t, source, "line", "source should contain line")
 	assert.Contains(t, source["file"], "logger_test.go", "source file should be logger_test.go")
 	assert.Contains(t, source["function"], "TestLoggerSource_WithError", "source function should contain TestLoggerSource_WithError")
-	assert.Equal(t, "test error", data["error"], "error message should be present")
+	assert.Equal(t, "test error", data["error"].(map[string]any)["message"], "error message should be present")
 }
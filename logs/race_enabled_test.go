@@ -0,0 +1,7 @@
+//go:build race
+
+package logs
+
+// raceEnabled reports whether the race detector is on, as it makes
+// allocation counts unreliable (sync.Pool drops items randomly).
+const raceEnabled = true
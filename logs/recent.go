@@ -0,0 +1,267 @@
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultRecentLogsSize = 1000
+
+var defaultRecentLogs atomic.Pointer[RecentLogs]
+
+// RecentOptions configures the recent logs buffer.
+type RecentOptions struct {
+	// Size is the number of records kept (1000 when zero).
+	Size int
+	// Level is the minimum level of the records kept.
+	Level slog.Level
+}
+
+// RecentOptionsFromConfig returns the recent logs buffer options defined by
+// the `log.recent.*` config keys, and whether it is enabled.
+func RecentOptionsFromConfig() (RecentOptions, bool) {
+	cfg := configs.GetLogRecent()
+	return RecentOptions{
+		Size:  cfg.Size,
+		Level: parseLogLevel(cfg.Level),
+	}, cfg.Enabled
+}
+
+// RecentRecord is a log record kept by RecentLogs.
+type RecentRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Logger  string         `json:"logger,omitempty"`
+	TraceID string         `json:"trace_id,omitempty"`
+	SpanID  string         `json:"span_id,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+
+	level slog.Level
+	seq   uint64
+}
+
+// RecentFilter selects the records returned by RecentLogs.Records. Zero
+// fields do not filter.
+type RecentFilter struct {
+	// Level is the minimum level of the records.
+	Level slog.Leveler
+	// Logger selects the records of the logger and its children (`http`
+	// matches `http` and `http.client`).
+	Logger string
+	// TraceID selects the records of a trace.
+	TraceID string
+	// Contains selects the records whose message or attribute values contain
+	// the substring (case-insensitive).
+	Contains string
+	// Since selects the records logged at or after the time.
+	Since time.Time
+	// Limit keeps the most recent records only.
+	Limit int
+}
+
+// RecentLogs keeps the last records logged in a lock-free ring buffer,
+// overwriting the oldest ones.
+type RecentLogs struct {
+	level slog.Level
+	slots []atomic.Pointer[RecentRecord]
+	next  atomic.Uint64
+}
+
+// NewRecentLogs creates a buffer keeping the last records at or above the
+// level of opts. Handler tees the records of a handler into it.
+func NewRecentLogs(opts RecentOptions) *RecentLogs {
+	if opts.Size <= 0 {
+		opts.Size = defaultRecentLogsSize
+	}
+	return &RecentLogs{
+		level: opts.Level,
+		slots: make([]atomic.Pointer[RecentRecord], opts.Size),
+	}
+}
+
+// SetDefaultRecentLogs defines the buffer served by default by the recent
+// logs endpoint (set by setup.InitSetup when `log.recent.enabled` is set).
+func SetDefaultRecentLogs(r *RecentLogs) {
+	defaultRecentLogs.Store(r)
+}
+
+// DefaultRecentLogs returns the default buffer, nil when not defined.
+func DefaultRecentLogs() *RecentLogs {
+	return defaultRecentLogs.Load()
+}
+
+func (r *RecentLogs) add(rec *RecentRecord) {
+	rec.seq = r.next.Add(1) - 1
+	r.slots[rec.seq%uint64(len(r.slots))].Store(rec)
+}
+
+// Records returns the records kept matching the filter, oldest first.
+func (r *RecentLogs) Records(filter RecentFilter) []RecentRecord {
+	end := r.next.Load()
+	start := uint64(0)
+	if size := uint64(len(r.slots)); end > size {
+		start = end - size
+	}
+	filter.Logger = strings.ToLower(filter.Logger)
+	filter.Contains = strings.ToLower(filter.Contains)
+
+	var records []RecentRecord
+	for seq := start; seq < end; seq++ {
+		rec := r.slots[seq%uint64(len(r.slots))].Load()
+		// the slot may be overwritten by a newer record, or not written yet
+		if rec == nil || rec.seq != seq || !filter.match(rec) {
+			continue
+		}
+		records = append(records, *rec)
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records
+}
+
+func (f RecentFilter) match(rec *RecentRecord) bool {
+	if f.Level != nil && rec.level < f.Level.Level() {
+		return false
+	}
+	if f.Logger != "" {
+		name := strings.ToLower(rec.Logger)
+		if name != f.Logger && !(strings.HasPrefix(name, f.Logger) && isNameSeparator(name[len(f.Logger)])) {
+			return false
+		}
+	}
+	if f.TraceID != "" && rec.TraceID != f.TraceID {
+		return false
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(strings.ToLower(rec.Message), f.Contains) {
+		for _, v := range rec.Attrs {
+			if s, ok := v.(string); ok && strings.Contains(strings.ToLower(s), f.Contains) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Handler returns a handler writing the records to h and keeping them in
+// the buffer.
+func (r *RecentLogs) Handler(h slog.Handler) slog.Handler {
+	return &recentHandler{h: h, recent: r}
+}
+
+type recentHandler struct {
+	h      slog.Handler
+	recent *RecentLogs
+
+	// attrs are the attributes added by WithAttrs, qualified by their groups.
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (t *recentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= t.recent.level || t.h.Enabled(ctx, level)
+}
+
+func (t *recentHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= t.recent.level {
+		t.recent.add(t.recentRecord(ctx, record))
+	}
+	if !t.h.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return t.h.Handle(ctx, record)
+}
+
+func (t *recentHandler) recentRecord(ctx context.Context, record slog.Record) *RecentRecord {
+	rec := &RecentRecord{
+		Time:    record.Time,
+		Level:   LevelName(record.Level),
+		Message: record.Message,
+		level:   record.Level,
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.TraceID = sc.TraceID().String()
+		rec.SpanID = sc.SpanID().String()
+	}
+	if len(t.attrs) > 0 || record.NumAttrs() > 0 {
+		rec.Attrs = make(map[string]any, len(t.attrs)+record.NumAttrs())
+	}
+	for _, a := range t.attrs {
+		setRecentAttr(rec, "", a)
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		setRecentAttr(rec, t.prefix, a)
+		return true
+	})
+	return rec
+}
+
+// setRecentAttr adds the attribute to the record, flattening the groups
+// into dotted keys.
+func setRecentAttr(rec *RecentRecord, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			setRecentAttr(rec, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	if prefix == "" && a.Key == LoggerNameKey {
+		rec.Logger = a.Value.String()
+	}
+	switch a.Value.Kind() {
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			rec.Attrs[prefix+a.Key] = err.Error()
+			return
+		}
+		rec.Attrs[prefix+a.Key] = a.Value.Any()
+	case slog.KindTime, slog.KindDuration, slog.KindString:
+		rec.Attrs[prefix+a.Key] = a.Value.String()
+	default:
+		rec.Attrs[prefix+a.Key] = a.Value.Any()
+	}
+}
+
+func (t *recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return t
+	}
+	t2 := *t
+	t2.h = t.h.WithAttrs(attrs)
+	t2.attrs = t.attrs[:len(t.attrs):len(t.attrs)]
+	for _, a := range attrs {
+		if t.prefix != "" {
+			a = slog.Attr{Key: strings.TrimSuffix(t.prefix, "."), Value: slog.GroupValue(a)}
+		}
+		t2.attrs = append(t2.attrs, a)
+	}
+	return &t2
+}
+
+func (t *recentHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return t
+	}
+	t2 := *t
+	t2.h = t.h.WithGroup(name)
+	t2.prefix = t.prefix + name + "."
+	return &t2
+}
@@ -0,0 +1,116 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentLogs(t *testing.T) {
+	t.Run("keeps the last records", func(t *testing.T) {
+		recent := NewRecentLogs(RecentOptions{Size: 3, Level: slog.LevelDebug})
+		l := slog.New(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+		for i := range 5 {
+			l.Info(fmt.Sprintf("message %d", i))
+		}
+
+		records := recent.Records(RecentFilter{})
+		require.Len(t, records, 3)
+		assert.Equal(t, "message 2", records[0].Message)
+		assert.Equal(t, "message 4", records[2].Message)
+	})
+
+	t.Run("tees the records at or above its level", func(t *testing.T) {
+		var buf bytes.Buffer
+		recent := NewRecentLogs(RecentOptions{Level: slog.LevelDebug})
+		l := slog.New(recent.Handler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+		l.Debug("debug only kept")
+		l.Info("both")
+
+		assert.Len(t, decodeLogLines(t, &buf), 1)
+		assert.Len(t, recent.Records(RecentFilter{}), 2)
+	})
+
+	t.Run("records the attributes, logger and trace", func(t *testing.T) {
+		recent := NewRecentLogs(RecentOptions{})
+		l := slog.New(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil))).
+			With(LoggerNameKey, "http.client").WithGroup("req").With("method", "GET")
+		l.InfoContext(spanContext(t), "done", "status", 200)
+
+		records := recent.Records(RecentFilter{})
+		require.Len(t, records, 1)
+		rec := records[0]
+		assert.Equal(t, "info", rec.Level)
+		assert.Equal(t, "http.client", rec.Logger)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec.TraceID)
+		assert.Equal(t, map[string]any{LoggerNameKey: "http.client", "req.method": "GET", "req.status": int64(200)}, rec.Attrs)
+	})
+
+	t.Run("filters the records", func(t *testing.T) {
+		recent := NewRecentLogs(RecentOptions{Level: slog.LevelDebug})
+		l := slog.New(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+		l.Debug("cache miss", LoggerNameKey, "cache")
+		l.Warn("slow query", LoggerNameKey, "db.postgres", "table", "users")
+		l.ErrorContext(spanContext(t), "query failed", LoggerNameKey, "db")
+		l.Info("database ready", LoggerNameKey, "dbx")
+
+		messages := func(f RecentFilter) []string {
+			var msgs []string
+			for _, r := range recent.Records(f) {
+				msgs = append(msgs, r.Message)
+			}
+			return msgs
+		}
+		assert.Equal(t, []string{"slow query", "query failed"}, messages(RecentFilter{Level: slog.LevelWarn}))
+		assert.Equal(t, []string{"slow query", "query failed"}, messages(RecentFilter{Logger: "DB"}))
+		assert.Equal(t, []string{"query failed"}, messages(RecentFilter{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"}))
+		assert.Equal(t, []string{"slow query"}, messages(RecentFilter{Contains: "USERS"}))
+		assert.Equal(t, []string{"database ready"}, messages(RecentFilter{Limit: 1}))
+		assert.Empty(t, messages(RecentFilter{Since: time.Now().Add(time.Minute)}))
+	})
+
+	t.Run("supports concurrent writers and readers", func(t *testing.T) {
+		recent := NewRecentLogs(RecentOptions{Size: 16})
+		l := slog.New(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Go(func() {
+				for range 100 {
+					l.Info("concurrent")
+					_ = recent.Records(RecentFilter{})
+				}
+			})
+		}
+		wg.Wait()
+		assert.Len(t, recent.Records(RecentFilter{}), 16)
+	})
+}
+
+func TestRecentOptionsFromConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("log.recent.enabled", true)
+	viper.Set("log.recent.size", 50)
+	viper.Set("log.recent.level", "WARN")
+
+	opts, enabled := RecentOptionsFromConfig()
+	assert.True(t, enabled)
+	assert.Equal(t, RecentOptions{Size: 50, Level: slog.LevelWarn}, opts)
+}
+
+// ensure the tee handler is not skipped by the level router for the levels it keeps
+func TestRecentLogs_BehindLevelRouter(t *testing.T) {
+	recent := NewRecentLogs(RecentOptions{Level: slog.LevelInfo})
+	router := NewLevelRouter(slog.LevelInfo, nil)
+	l := slog.New(NewLevelRouterHandler(recent.Handler(slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelError})), router))
+	l.InfoContext(context.Background(), "kept")
+
+	assert.Len(t, recent.Records(RecentFilter{}), 1)
+}
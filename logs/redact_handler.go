@@ -4,22 +4,30 @@ import (
 	"context"
 	"log/slog"
 	"reflect"
-	"strings"
+	"sync"
+)
+
+var (
+	stringType = reflect.TypeFor[string]()
+	mapKeyPool = sync.Pool{New: func() any { return new(string) }}
 )
 
 type redactHandler struct {
-	h            slog.Handler
-	keysToRedact []string
+	h        slog.Handler
+	redactor *Redactor
+	groups   []string
 }
 
-func NewRedactHandler(h slog.Handler, keysToRedact []string) slog.Handler {
-	loweredKeys := make([]string, len(keysToRedact))
-	for i, k := range keysToRedact {
-		loweredKeys[i] = strings.ToLower(k)
-	}
+// NewRedactHandler wraps h, masking the attributes whose keys contain any of keysToRedact.
+func NewRedactHandler(h slog.Handler, keysToRedact []string, opts ...RedactorOption) slog.Handler {
+	return NewRedactHandlerWithRedactor(h, NewRedactor(keysToRedact, opts...))
+}
+
+// NewRedactHandlerWithRedactor wraps h using the rules of the given Redactor.
+func NewRedactHandlerWithRedactor(h slog.Handler, redactor *Redactor) slog.Handler {
 	return &redactHandler{
-		h:            h,
-		keysToRedact: loweredKeys,
+		h:        h,
+		redactor: redactor,
 	}
 }
 
@@ -27,178 +35,324 @@ func (r *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
 	return r.h.Enabled(ctx, level)
 }
 
+// Handle passes the record through untouched unless its message or one of
+// its attributes actually needs redaction, in which case a redacted copy of
+// the record is built.
 func (r *redactHandler) Handle(ctx context.Context, record slog.Record) error {
-	if len(r.keysToRedact) == 0 {
+	if r.redactor.Empty() {
 		return r.h.Handle(ctx, record)
 	}
 
-	newRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	msg, changed := r.redactor.RedactString(record.Message)
+	if !changed {
+		record.Attrs(func(attr slog.Attr) bool {
+			_, changed = r.redactAttr(r.groups, attr)
+			return !changed
+		})
+	}
+	if !changed {
+		return r.h.Handle(ctx, record)
+	}
+
+	newRecord := slog.NewRecord(record.Time, record.Level, msg, record.PC)
 	record.Attrs(func(attr slog.Attr) bool {
-		newRecord.AddAttrs(r.redactAttr(attr))
+		redacted, _ := r.redactAttr(r.groups, attr)
+		newRecord.AddAttrs(redacted)
 		return true
 	})
 	return r.h.Handle(ctx, newRecord)
 }
 
 func (r *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	if len(r.keysToRedact) == 0 {
-		return &redactHandler{h: r.h.WithAttrs(attrs), keysToRedact: r.keysToRedact}
-	}
-
-	newAttrs := make([]slog.Attr, len(attrs))
+	var newAttrs []slog.Attr
 	for i, attr := range attrs {
-		newAttrs[i] = r.redactAttr(attr)
+		redacted, changed := r.redactAttr(r.groups, attr)
+		if !changed {
+			continue
+		}
+		if newAttrs == nil {
+			newAttrs = make([]slog.Attr, len(attrs))
+			copy(newAttrs, attrs)
+		}
+		newAttrs[i] = redacted
+	}
+	if newAttrs == nil {
+		newAttrs = attrs
 	}
 
-	return &redactHandler{h: r.h.WithAttrs(newAttrs), keysToRedact: r.keysToRedact}
+	return &redactHandler{h: r.h.WithAttrs(newAttrs), redactor: r.redactor, groups: r.groups}
 }
 
 func (r *redactHandler) WithGroup(name string) slog.Handler {
-	return &redactHandler{h: r.h.WithGroup(name), keysToRedact: r.keysToRedact}
+	if name == "" {
+		return r
+	}
+	return &redactHandler{
+		h:        r.h.WithGroup(name),
+		redactor: r.redactor,
+		groups:   append(r.groups[:len(r.groups):len(r.groups)], name),
+	}
 }
 
-func (r *redactHandler) shouldRedact(key string) bool {
-	lowerKey := strings.ToLower(key)
-	for _, rk := range r.keysToRedact {
-		if strings.Contains(lowerKey, rk) {
-			return true
-		}
+// childPath returns path extended by key, never sharing the backing array
+// with its siblings. Paths are only tracked when path matchers are defined.
+func (r *redactHandler) childPath(path []string, key string) []string {
+	if !r.redactor.tracksPaths() {
+		return nil
 	}
-	return false
+	return append(path[:len(path):len(path)], key)
 }
 
-func (r *redactHandler) redactAttr(attr slog.Attr) slog.Attr {
-	if r.shouldRedact(attr.Key) {
-		return slog.String(attr.Key, "***")
+// redactAttr returns the redacted attribute and whether it was changed.
+func (r *redactHandler) redactAttr(path []string, attr slog.Attr) (slog.Attr, bool) {
+	if r.redactor.Empty() {
+		return attr, false
+	}
+	if r.redactor.ShouldRedactPath(path, attr.Key) {
+		return slog.String(attr.Key, r.redactor.MaskString(attr.Value.String())), true
 	}
 
-	switch attr.Value.Kind() {
+	v := attr.Value
+	if v.Kind() == slog.KindLogValuer {
+		v = v.Resolve()
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		if s, ok := r.redactor.RedactString(v.String()); ok {
+			return slog.String(attr.Key, s), true
+		}
 	case slog.KindGroup:
-		groupAttrs := attr.Value.Group()
-		newGroupAttrs := make([]slog.Attr, len(groupAttrs))
+		groupAttrs := v.Group()
+		groupPath := path
+		if attr.Key != "" {
+			groupPath = r.childPath(path, attr.Key)
+		}
+		var newGroupAttrs []any
 		for i, a := range groupAttrs {
-			newGroupAttrs[i] = r.redactAttr(a)
+			redacted, changed := r.redactAttr(groupPath, a)
+			if !changed {
+				if newGroupAttrs != nil {
+					newGroupAttrs = append(newGroupAttrs, a)
+				}
+				continue
+			}
+			if newGroupAttrs == nil {
+				newGroupAttrs = make([]any, i, len(groupAttrs))
+				for j := range i {
+					newGroupAttrs[j] = groupAttrs[j]
+				}
+			}
+			newGroupAttrs = append(newGroupAttrs, redacted)
 		}
-		args := make([]any, len(newGroupAttrs))
-		for i, v := range newGroupAttrs {
-			args[i] = v
+		if newGroupAttrs != nil {
+			return slog.Group(attr.Key, newGroupAttrs...), true
 		}
-		return slog.Group(attr.Key, args...)
 	case slog.KindAny:
-		val := attr.Value.Any()
-		if val != nil {
-			return slog.Any(attr.Key, r.redactValue(val))
+		val := v.Any()
+		if val == nil {
+			return attr, false
+		}
+		if redacted, changed := r.redactValue(r.childPath(path, attr.Key), reflect.ValueOf(val)); changed {
+			return slog.Any(attr.Key, redacted.Interface()), true
 		}
 	}
 
-	return attr
+	return attr, false
 }
 
-func (r *redactHandler) redactValue(v any) any {
-	if v == nil {
-		return nil
+// redactValue returns the redacted copy of v and true, or v and false when
+// nothing had to be redacted. Containers are copied on write: they are only
+// cloned once one of their elements changes.
+func (r *redactHandler) redactValue(path []string, v reflect.Value) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		return r.redactValue(path, v.Elem())
 	}
 
-	vVal := reflect.ValueOf(v)
-	vType := vVal.Type()
+	plan := r.redactor.planFor(v.Type())
+	if !plan.mayChange {
+		return v, false
+	}
 
-	switch vType.Kind() {
-	case reflect.Map:
-		if vType.Key().Kind() == reflect.String {
-			return r.redactMap(vVal)
+	if v.Type().Implements(errorType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return v, false
 		}
+		if s, ok := r.redactor.RedactString(v.Interface().(error).Error()); ok {
+			return reflect.ValueOf(s), true
+		}
+		return v, false
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if s, ok := r.redactor.RedactString(v.String()); ok {
+			return reflect.ValueOf(s).Convert(v.Type()), true
+		}
+	case reflect.Map:
+		return r.redactMap(path, v)
 	case reflect.Struct:
-		return r.redactStruct(vVal)
+		return r.redactStruct(path, v, plan)
 	case reflect.Pointer:
-		if vVal.IsNil() {
-			return v
+		if v.IsNil() {
+			return v, false
 		}
-		return r.redactValue(vVal.Elem().Interface())
+		elem, changed := r.redactValue(path, v.Elem())
+		if !changed || !elem.Type().AssignableTo(v.Type().Elem()) {
+			return v, false
+		}
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(elem)
+		return ptr, true
 	case reflect.Slice, reflect.Array:
-		return r.redactSlice(vVal)
+		return r.redactSlice(path, v)
 	}
 
-	return v
+	return v, false
 }
 
-func (r *redactHandler) redactMap(v reflect.Value) any {
-	newMap := reflect.MakeMap(v.Type())
-	for _, key := range v.MapKeys() {
+func (r *redactHandler) redactMap(path []string, v reflect.Value) (reflect.Value, bool) {
+	if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+		return v, false
+	}
+	var newMap reflect.Value
+	// the iteration key and value are reused, plain string keys use a pooled
+	// buffer and values are only read when they may need redaction
+	var key reflect.Value
+	if v.Type().Key() == stringType {
+		keyBuf := mapKeyPool.Get().(*string)
+		defer func() {
+			*keyBuf = ""
+			mapKeyPool.Put(keyBuf)
+		}()
+		key = reflect.ValueOf(keyBuf).Elem()
+	} else {
+		key = reflect.New(v.Type().Key()).Elem()
+	}
+	elemMayChange := r.redactor.planFor(v.Type().Elem()).mayChange
+	var val reflect.Value
+	if elemMayChange {
+		val = reflect.New(v.Type().Elem()).Elem()
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		key.SetIterKey(iter)
+
+		var redacted reflect.Value
 		kStr := key.String()
-		val := v.MapIndex(key)
-		if r.shouldRedact(kStr) {
-			newMap.SetMapIndex(key, r.zeroValue(val))
+		if r.redactor.ShouldRedactPath(path, kStr) {
+			redacted = r.zeroValue(iter.Value())
 		} else {
-			redactedVal := r.redactValue(val.Interface())
-			newMap.SetMapIndex(key, reflect.ValueOf(redactedVal))
+			if !elemMayChange {
+				continue
+			}
+			val.SetIterValue(iter)
+			rv, changed := r.redactValue(r.childPath(path, kStr), val)
+			if !changed || !rv.Type().AssignableTo(v.Type().Elem()) {
+				continue
+			}
+			redacted = rv
 		}
-	}
-	return newMap.Interface()
-}
-
-func (r *redactHandler) redactStruct(v reflect.Value) any {
-	vType := v.Type()
-	newStruct := reflect.New(vType).Elem()
 
-	for i := 0; i < vType.NumField(); i++ {
-		field := vType.Field(i)
-		if !field.IsExported() {
-			continue
+		if !newMap.IsValid() {
+			newMap = reflect.MakeMapWithSize(v.Type(), v.Len())
+			copyIter := v.MapRange()
+			for copyIter.Next() {
+				newMap.SetMapIndex(copyIter.Key(), copyIter.Value())
+			}
 		}
+		newMap.SetMapIndex(key, redacted)
+	}
+	if !newMap.IsValid() {
+		return v, false
+	}
+	return newMap, true
+}
 
-		fieldVal := v.Field(i)
+func (r *redactHandler) redactStruct(path []string, v reflect.Value, plan *typePlan) (reflect.Value, bool) {
+	var newStruct reflect.Value
+	for _, fp := range plan.fields {
+		fieldVal := v.Field(fp.index)
 
-		redacted := r.shouldRedact(field.Name)
-		if !redacted {
-			if tag := field.Tag.Get("json"); tag != "" {
-				tagPart := strings.Split(tag, ",")[0]
-				if tagPart != "" && tagPart != "-" {
-					redacted = r.shouldRedact(tagPart)
-				}
+		var redacted reflect.Value
+		if fp.mask || r.redactor.keys.matchPath(path, fp.goName) || r.redactor.keys.matchPath(path, fp.name) {
+			redacted = r.zeroValue(fieldVal)
+		} else {
+			if !fp.recurse {
+				continue
+			}
+			rv, changed := r.redactValue(r.childPath(path, fp.name), fieldVal)
+			if !changed || !rv.Type().AssignableTo(fieldVal.Type()) {
+				continue
 			}
+			redacted = rv
 		}
 
-		if redacted {
-			newStruct.Field(i).Set(r.zeroValue(fieldVal))
-		} else {
-			redactedVal := r.redactValue(fieldVal.Interface())
-			rv := reflect.ValueOf(redactedVal)
-			if rv.Type().AssignableTo(field.Type) {
-				newStruct.Field(i).Set(rv)
-			} else {
-				newStruct.Field(i).Set(fieldVal)
-			}
+		if !newStruct.IsValid() {
+			// copying the whole struct preserves its unexported fields
+			newStruct = reflect.New(v.Type()).Elem()
+			newStruct.Set(v)
 		}
+		newStruct.Field(fp.index).Set(redacted)
 	}
-	return newStruct.Interface()
+	if !newStruct.IsValid() {
+		return v, false
+	}
+	return newStruct, true
 }
 
-func (r *redactHandler) redactSlice(v reflect.Value) any {
-	newSlice := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+func (r *redactHandler) redactSlice(path []string, v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return v, false
+	}
+	var newSlice reflect.Value
 	for i := 0; i < v.Len(); i++ {
-		redactedVal := r.redactValue(v.Index(i).Interface())
-		rv := reflect.ValueOf(redactedVal)
-		if rv.Type().AssignableTo(v.Type().Elem()) {
-			newSlice.Index(i).Set(rv)
-		} else {
-			newSlice.Index(i).Set(v.Index(i))
+		rv, changed := r.redactValue(path, v.Index(i))
+		if !changed || !rv.Type().AssignableTo(v.Type().Elem()) {
+			continue
 		}
+		if !newSlice.IsValid() {
+			if v.Kind() == reflect.Array {
+				newSlice = reflect.New(v.Type()).Elem()
+			} else {
+				newSlice = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			}
+			reflect.Copy(newSlice, v)
+		}
+		newSlice.Index(i).Set(rv)
 	}
-	return newSlice.Interface()
+	if !newSlice.IsValid() {
+		return v, false
+	}
+	return newSlice, true
 }
 
+// zeroValue returns the replacement for a value stored under a redacted key:
+// the masked value for strings (and interfaces holding strings), the masked
+// elements for string slices and the zero value for any other type.
 func (r *redactHandler) zeroValue(val reflect.Value) reflect.Value {
 	vType := val.Type()
 
-	if reflect.TypeFor[string]().AssignableTo(vType) {
-		return reflect.ValueOf("***")
-	}
-
 	switch vType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(r.redactor.MaskString(val.String())).Convert(vType)
+	case reflect.Interface:
+		if !val.IsNil() && val.Elem().Kind() == reflect.String {
+			return reflect.ValueOf(r.redactor.MaskString(val.Elem().String()))
+		}
+		return reflect.Zero(vType)
 	case reflect.Slice:
 		if vType.Elem().Kind() == reflect.String {
-			redactedSlice := reflect.MakeSlice(vType, 1, 1)
-			redactedSlice.Index(0).Set(reflect.ValueOf("***"))
+			redactedSlice := reflect.MakeSlice(vType, val.Len(), val.Len())
+			for i := range val.Len() {
+				redactedSlice.Index(i).Set(reflect.ValueOf(r.redactor.MaskString(val.Index(i).String())).Convert(vType.Elem()))
+			}
 			return redactedSlice
 		}
 		return reflect.Zero(vType)
@@ -69,4 +69,47 @@ func BenchmarkRedactHandler(b *testing.B) {
 			}
 		})
 	})
+
+	b.Run("no matching keys", func(b *testing.B) {
+		user := benchUser{Name: strAttr0, Email: strAttr1, Age: 42}
+		logAttrs := func(b *testing.B, l *slog.Logger) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Info("benchmarkingHandlerTest",
+					slog.String("no_redacted_attr", strAttr2),
+					slog.Int("count", i),
+					slog.Any("user", user),
+				)
+			}
+		}
+
+		b.Run("unwrapped handler", func(b *testing.B) {
+			logAttrs(b, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+		})
+		b.Run("redact handler", func(b *testing.B) {
+			logAttrs(b, slog.New(NewRedactHandler(slog.NewJSONHandler(io.Discard, nil), []string{"password", "token"})))
+		})
+	})
+
+	b.Run("struct values", func(b *testing.B) {
+		l := slog.New(NewRedactHandler(slog.NewJSONHandler(io.Discard, nil), []string{"password"}))
+		login := benchLogin{User: strAttr0, Password: strAttr1}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("benchmarkingHandlerTest", slog.Any("login", login))
+		}
+	})
+}
+
+type benchUser struct {
+	Name  string
+	Email string
+	Age   int
+}
+
+type benchLogin struct {
+	User     string
+	Password string
 }
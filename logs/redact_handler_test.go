@@ -3,14 +3,17 @@ package logs
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"log/slog"
 	"maps"
 	"strings"
 	"testing"
 	"testing/slogtest"
+	"time"
 
 	"github.com/eldius/initial-config-go/configs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRedactValues_Handler(t *testing.T) {
@@ -193,6 +196,25 @@ func TestRedactValues_Handler(t *testing.T) {
 	})
 }
 
+func TestRedactHandler_MasksActualValues(t *testing.T) {
+	mask, err := NewMaskFunc("hash", []byte("hash-key"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"authentication", "token"}, WithMask(mask)))
+
+	logger.Info("request", "request", map[string]any{
+		"headers": map[string][]string{"Authentication": {"key-1", "key-2"}},
+		"token":   "secret",
+		"other":   map[string]any{"token": 42},
+	})
+
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, []any{mask("key-1"), mask("key-2")}, getLogEntryAttrValue(t, m, "request", "headers", "Authentication"))
+	assert.Equal(t, mask("secret"), getLogEntryAttrValue(t, m, "request", "token"))
+	assert.Nil(t, getLogEntryAttrValue(t, m, "request", "other", "token"))
+}
+
 func TestRedact_Handle_Attributes(t *testing.T) {
 	var buf bytes.Buffer
 	handler := NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"password"})
@@ -243,3 +265,66 @@ type testStruct struct {
 	ContentType    string `json:"Content-type"`
 	Accept         string `json:"Accept"`
 }
+
+type secretValuer struct {
+	user     string
+	password string
+}
+
+func (s secretValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user", s.user), slog.String("password", s.password))
+}
+
+type auditEntry struct {
+	At       time.Time
+	Token    string
+	Metadata map[string]int
+}
+
+func TestRedactHandler_FastPath(t *testing.T) {
+	t.Run("resolves LogValuer values", func(t *testing.T) {
+		h, buf := newTestRedactHandler(t, []string{"password"})
+		slog.New(h).Info("test", "login", secretValuer{user: "admin", password: "secret"})
+
+		m := make(map[string]any)
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "login", "password"))
+		assert.Equal(t, "admin", getLogEntryAttrValue(t, m, "login", "user"))
+	})
+
+	t.Run("keeps the unexported state of redacted structs", func(t *testing.T) {
+		at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		h, buf := newTestRedactHandler(t, []string{"token"})
+		slog.New(h).Info("test", "audit", auditEntry{At: at, Token: "secret", Metadata: map[string]int{"a": 1}})
+
+		m := make(map[string]any)
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		assert.Equal(t, "***", getLogEntryAttrValue(t, m, "audit", "Token"))
+		assert.Equal(t, at.Format(time.RFC3339), getLogEntryAttrValue(t, m, "audit", "At"))
+		assert.Equal(t, float64(1), getLogEntryAttrValue(t, m, "audit", "Metadata", "a"))
+	})
+
+	t.Run("does not modify the logged values", func(t *testing.T) {
+		h, _ := newTestRedactHandler(t, []string{"token"})
+		headers := map[string]any{"token": "secret", "accept": "*/*"}
+		slog.New(h).Info("test", "headers", headers)
+
+		assert.Equal(t, "secret", headers["token"])
+	})
+
+	t.Run("allocates as the wrapped handler when no keys match", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("allocation counts are not reliable with the race detector")
+		}
+		entry := auditEntry{At: time.Now(), Metadata: map[string]int{"a": 1}}
+		allocs := func(l *slog.Logger) float64 {
+			return testing.AllocsPerRun(100, func() {
+				l.Info("test", slog.String("user", "admin"), slog.Int("count", 1), slog.Any("audit", entry))
+			})
+		}
+
+		unwrapped := allocs(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+		redacted := allocs(slog.New(NewRedactHandler(slog.NewJSONHandler(io.Discard, nil), []string{"password", "secret"})))
+		assert.Equal(t, unwrapped, redacted)
+	})
+}
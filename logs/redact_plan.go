@@ -0,0 +1,106 @@
+package logs
+
+import (
+	"reflect"
+	"strings"
+)
+
+var errorType = reflect.TypeFor[error]()
+
+// typePlan describes how values of a type are redacted. It is computed once
+// per type and cached in the Redactor.
+type typePlan struct {
+	// mayChange is false when no value of the type can ever be redacted
+	// (e.g. numbers, time.Time or structs without sensitive fields), so it is
+	// passed through without any reflection.
+	mayChange bool
+	// fields is the plan of each exported struct field.
+	fields []fieldPlan
+}
+
+type fieldPlan struct {
+	index int
+	// name is the field name used in paths: its json tag or its Go name.
+	name   string
+	goName string
+	// mask is true when the field is always redacted (redact tag or key match).
+	mask bool
+	// recurse is true when the field value may contain data to redact.
+	recurse bool
+}
+
+// planFor returns the cached plan for t, computing it when needed.
+func (r *Redactor) planFor(t reflect.Type) *typePlan {
+	if p, ok := r.plans.Load(t); ok {
+		return p.(*typePlan)
+	}
+	p := r.buildPlan(t, map[reflect.Type]*typePlan{})
+	actual, _ := r.plans.LoadOrStore(t, p)
+	return actual.(*typePlan)
+}
+
+func (r *Redactor) buildPlan(t reflect.Type, inProgress map[reflect.Type]*typePlan) *typePlan {
+	if p, ok := r.plans.Load(t); ok {
+		return p.(*typePlan)
+	}
+	if p, ok := inProgress[t]; ok {
+		// recursive type, assume it may change
+		p.mayChange = true
+		return p
+	}
+	p := &typePlan{}
+	inProgress[t] = p
+
+	hasKeys := !r.keys.empty()
+	hasValueRules := len(r.valueRules) > 0
+
+	switch {
+	case t.Kind() == reflect.Interface:
+		p.mayChange = true
+	case t.Implements(errorType):
+		p.mayChange = hasValueRules
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			p.mayChange = hasValueRules
+		case reflect.Map:
+			p.mayChange = t.Key().Kind() == reflect.String &&
+				(hasKeys || r.buildPlan(t.Elem(), inProgress).mayChange)
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			p.mayChange = r.buildPlan(t.Elem(), inProgress).mayChange
+		case reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if !field.IsExported() {
+					continue
+				}
+				name := structFieldName(field)
+				fp := fieldPlan{
+					index:   i,
+					name:    name,
+					goName:  field.Name,
+					mask:    field.Tag.Get("redact") == "true" || r.keys.matchKey(field.Name) || r.keys.matchKey(name),
+					recurse: r.buildPlan(field.Type, inProgress).mayChange,
+				}
+				p.fields = append(p.fields, fp)
+				p.mayChange = p.mayChange || fp.mask || fp.recurse || r.tracksPaths()
+			}
+		}
+	}
+
+	delete(inProgress, t)
+	r.plans.Store(t, p)
+	return p
+}
+
+// structFieldName returns the name used for a field in attribute paths:
+// its json tag name when defined, or its Go name otherwise.
+func structFieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("json"); tag != "" {
+		tagPart, _, _ := strings.Cut(tag, ",")
+		if tagPart != "" && tagPart != "-" {
+			return tagPart
+		}
+	}
+	return field.Name
+}
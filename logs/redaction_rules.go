@@ -0,0 +1,198 @@
+package logs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eldius/initial-config-go/configs"
+)
+
+var (
+	// ErrInvalidRedactionConfig is returned when a redaction rule or mask strategy is not valid.
+	ErrInvalidRedactionConfig = errors.New("invalid redaction configuration")
+)
+
+const partialMaskVisibleChars = 4
+
+// MaskFunc returns the replacement for a redacted value.
+type MaskFunc func(value string) string
+
+// ValueRule masks the matches of Pattern found inside string values.
+// When Pattern has capture groups only the first group is masked,
+// keeping the surrounding text (e.g. the `Bearer` prefix).
+type ValueRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate, when set, must return true for a match to be masked
+	// (e.g. the Luhn check for card numbers).
+	Validate func(match string) bool
+	// Mask overrides the Redactor default mask strategy.
+	Mask MaskFunc
+}
+
+var presets = map[string]ValueRule{
+	configs.RedactionPresetJWT: {
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	configs.RedactionPresetBearer: {
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	},
+	configs.RedactionPresetPAN: {
+		Pattern:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhnValid,
+	},
+	configs.RedactionPresetEmail: {
+		Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`),
+	},
+	configs.RedactionPresetIPv4: {
+		Pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+}
+
+// RedactionPreset returns one of the built-in value rules:
+// `jwt`, `bearer`, `pan` (validated with the Luhn check), `email` or `ipv4`.
+func RedactionPreset(name string) (ValueRule, error) {
+	rule, ok := presets[strings.ToLower(name)]
+	if !ok {
+		return ValueRule{}, fmt.Errorf("%w: unknown preset '%s'", ErrInvalidRedactionConfig, name)
+	}
+	rule.Name = strings.ToLower(name)
+	return rule, nil
+}
+
+// NewMaskFunc returns the mask function for the given strategy:
+// `full` replaces the value by `***`, `partial` keeps its last 4 characters
+// and `hash` replaces it by a keyed HMAC-SHA256, so equal values can still be
+// correlated across records without being disclosed.
+func NewMaskFunc(strategy string, hashKey []byte) (MaskFunc, error) {
+	switch strings.ToLower(strategy) {
+	case "", configs.RedactionMaskFull:
+		return fullMask, nil
+	case configs.RedactionMaskPartial:
+		return partialMask, nil
+	case configs.RedactionMaskHash:
+		if len(hashKey) == 0 {
+			return nil, fmt.Errorf("%w: hash mask requires a hash key", ErrInvalidRedactionConfig)
+		}
+		return hashMask(hashKey), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown mask strategy '%s'", ErrInvalidRedactionConfig, strategy)
+	}
+}
+
+func fullMask(_ string) string {
+	return redactedMask
+}
+
+func partialMask(value string) string {
+	runes := []rune(value)
+	if len(runes) <= partialMaskVisibleChars {
+		return redactedMask
+	}
+	return redactedMask + string(runes[len(runes)-partialMaskVisibleChars:])
+}
+
+func hashMask(key []byte) MaskFunc {
+	return func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum := 0
+	digits := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// NewValueRule builds a ValueRule from its configuration.
+func NewValueRule(p configs.RedactionPattern, hashKey []byte) (ValueRule, error) {
+	var rule ValueRule
+	switch {
+	case p.Preset != "":
+		preset, err := RedactionPreset(p.Preset)
+		if err != nil {
+			return ValueRule{}, err
+		}
+		rule = preset
+	case p.Regex != "":
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return ValueRule{}, fmt.Errorf("%w: pattern '%s': %w", ErrInvalidRedactionConfig, p.Name, err)
+		}
+		rule = ValueRule{Pattern: re}
+	default:
+		return ValueRule{}, fmt.Errorf("%w: pattern '%s' must define a preset or a regex", ErrInvalidRedactionConfig, p.Name)
+	}
+	if p.Name != "" {
+		rule.Name = p.Name
+	}
+	if p.Mask != "" {
+		mask, err := NewMaskFunc(p.Mask, hashKey)
+		if err != nil {
+			return ValueRule{}, err
+		}
+		rule.Mask = mask
+	}
+	return rule, nil
+}
+
+// RedactorOptionsFromConfig returns the Redactor options defined by the
+// `log.redaction.*` configuration keys.
+func RedactorOptionsFromConfig() ([]RedactorOption, error) {
+	hashKey := []byte(configs.GetLogRedactionHashKey())
+	strategy := configs.GetLogRedactionMask()
+
+	var opts []RedactorOption
+	if strategy != "" && strategy != configs.RedactionMaskFull {
+		mask, err := NewMaskFunc(strategy, hashKey)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithMask(mask))
+	}
+
+	patterns, err := configs.GetLogRedactionPatterns()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRedactionConfig, err)
+	}
+	rules := make([]ValueRule, 0, len(patterns))
+	for _, p := range patterns {
+		rule, err := NewValueRule(p, hashKey)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		opts = append(opts, WithValueRules(rules...))
+	}
+	return opts, nil
+}
@@ -0,0 +1,156 @@
+package logs
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redactedMask = "***"
+
+// Redactor holds the redaction rules shared by the slog redaction handler
+// and the OpenTelemetry span and log-record processors, so sensitive data
+// is masked the same way wherever it is exported.
+type Redactor struct {
+	keys       keyMatchers
+	valueRules []ValueRule
+	mask       MaskFunc
+	// plans caches the redaction plan of each inspected type
+	plans sync.Map
+}
+
+// RedactorOption customizes a Redactor.
+type RedactorOption func(*Redactor)
+
+// WithValuePatterns masks every match of the given patterns inside string
+// values, regardless of the attribute key.
+func WithValuePatterns(patterns ...*regexp.Regexp) RedactorOption {
+	return func(r *Redactor) {
+		for _, p := range patterns {
+			r.valueRules = append(r.valueRules, ValueRule{Pattern: p})
+		}
+	}
+}
+
+// WithValueRules masks the matches of the given rules inside string values,
+// regardless of the attribute key.
+func WithValueRules(rules ...ValueRule) RedactorOption {
+	return func(r *Redactor) {
+		r.valueRules = append(r.valueRules, rules...)
+	}
+}
+
+// WithMask sets the default mask applied to redacted values (see NewMaskFunc).
+func WithMask(mask MaskFunc) RedactorOption {
+	return func(r *Redactor) {
+		r.mask = mask
+	}
+}
+
+// NewRedactor creates a Redactor matching the given keys, ignoring case.
+//
+// Keys are matched by substring unless prefixed by a match mode:
+//   - `exact:token` matches the `token` key only
+//   - `prefix:x_` matches keys starting with `x_`
+//   - `glob:*_secret` matches keys using shell glob patterns
+//   - `path:request.headers.authorization` matches the dotted path of the
+//     value, built from slog groups, map keys and struct field (or json tag) names
+func NewRedactor(keysToRedact []string, opts ...RedactorOption) *Redactor {
+	r := &Redactor{
+		mask: fullMask,
+	}
+	for _, k := range keysToRedact {
+		if m, ok := parseKeyMatcher(k); ok {
+			r.keys.add(m)
+		}
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Empty reports whether the Redactor has no rules, so callers can skip it entirely.
+func (r *Redactor) Empty() bool {
+	return r == nil || (r.keys.empty() && len(r.valueRules) == 0)
+}
+
+// ShouldRedact reports whether the value stored under key must be masked.
+func (r *Redactor) ShouldRedact(key string) bool {
+	return r.ShouldRedactPath(nil, key)
+}
+
+// ShouldRedactPath reports whether the value stored under key, nested in
+// the given parent path (groups, map keys or struct fields), must be masked.
+func (r *Redactor) ShouldRedactPath(parents []string, key string) bool {
+	if r == nil {
+		return false
+	}
+	return r.keys.matchKey(key) || r.keys.matchPath(parents, key)
+}
+
+// tracksPaths reports whether values must be inspected with their parent path.
+func (r *Redactor) tracksPaths() bool {
+	return len(r.keys.paths) > 0
+}
+
+// RedactString masks the parts of s matching the value rules.
+// It returns the original string and false when nothing matched.
+func (r *Redactor) RedactString(s string) (string, bool) {
+	if r == nil || s == "" {
+		return s, false
+	}
+	changed := false
+	for _, rule := range r.valueRules {
+		if redacted, ok := r.applyRule(rule, s); ok {
+			s = redacted
+			changed = true
+		}
+	}
+	return s, changed
+}
+
+func (r *Redactor) applyRule(rule ValueRule, s string) (string, bool) {
+	if !rule.Pattern.MatchString(s) {
+		return s, false
+	}
+	matches := rule.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, false
+	}
+	mask := rule.Mask
+	if mask == nil {
+		mask = r.mask
+	}
+
+	var b strings.Builder
+	last := 0
+	changed := false
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		match := s[start:end]
+		if rule.Validate != nil && !rule.Validate(match) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(mask(match))
+		last = end
+		changed = true
+	}
+	if !changed {
+		return s, false
+	}
+	b.WriteString(s[last:])
+	return b.String(), true
+}
+
+// MaskString returns the value used to replace the redacted value s.
+func (r *Redactor) MaskString(s string) string {
+	if r == nil || r.mask == nil {
+		return redactedMask
+	}
+	return r.mask(s)
+}
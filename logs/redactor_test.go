@@ -0,0 +1,53 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Run("matches keys ignoring case", func(t *testing.T) {
+		r := NewRedactor([]string{"Password"})
+		assert.True(t, r.ShouldRedact("user_password"))
+		assert.True(t, r.ShouldRedact("PASSWORD"))
+		assert.False(t, r.ShouldRedact("user"))
+	})
+
+	t.Run("empty redactor", func(t *testing.T) {
+		assert.True(t, NewRedactor(nil).Empty())
+		assert.True(t, NewRedactor([]string{""}).Empty())
+		assert.True(t, (*Redactor)(nil).Empty())
+		assert.False(t, NewRedactor(nil, WithValuePatterns(regexp.MustCompile("x"))).Empty())
+	})
+
+	t.Run("masks value pattern matches", func(t *testing.T) {
+		r := NewRedactor(nil, WithValuePatterns(regexp.MustCompile(`token=\w+`)))
+		s, ok := r.RedactString("GET /path?token=abc123&page=1")
+		assert.True(t, ok)
+		assert.Equal(t, "GET /path?***&page=1", s)
+
+		s, ok = r.RedactString("GET /path?page=1")
+		assert.False(t, ok)
+		assert.Equal(t, "GET /path?page=1", s)
+	})
+}
+
+func TestRedactHandler_ValuePatterns(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewRedactHandler(slog.NewJSONHandler(&buf, nil), nil, WithValuePatterns(regexp.MustCompile(`secret-\d+`)))
+	slog.New(h).Info("test",
+		"url", "/api?key=secret-123",
+		"nested", map[string]any{"value": "secret-456"},
+	)
+
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "/api?key=***", m["url"])
+	assert.Equal(t, "***", m["nested"].(map[string]any)["value"])
+}
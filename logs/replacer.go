@@ -21,6 +21,12 @@ var logKeys = []string{
 
 func LogAttrsReplacerFunc() func(groups []string, a slog.Attr) slog.Attr {
 	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.LevelKey && len(groups) == 0 {
+			if level, ok := a.Value.Any().(slog.Level); ok && level >= LevelPanic {
+				a.Value = slog.StringValue(levelLabel(level))
+			}
+			return a
+		}
 		if slices.Contains(logKeys, a.Key) {
 			return a
 		}
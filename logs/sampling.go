@@ -0,0 +1,337 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// SamplingSummaryMessage is the message of the records reporting the
+	// entries dropped by the sampling handler.
+	SamplingSummaryMessage = "log entries dropped by sampling"
+
+	samplingMeterName = "github.com/eldius/initial-config-go/logs"
+
+	// samplingCounters is the size of the counter table: entries whose level
+	// and message hash to the same counter are sampled together.
+	samplingCounters = 4096
+)
+
+var (
+	samplersMu sync.Mutex
+	samplers   []*sampler
+)
+
+// SamplingBudget is the number of identical entries logged per interval: the
+// first Initial ones, then every Thereafter-th one (none when zero).
+type SamplingBudget = configs.SamplingBudget
+
+// SamplingOptions configures the sampling handler.
+type SamplingOptions struct {
+	// Interval is the period over which identical entries are counted.
+	Interval time.Duration
+	// Budget applies to the levels without a specific budget.
+	Budget SamplingBudget
+	// Levels holds the budgets of specific levels.
+	Levels map[slog.Level]SamplingBudget
+	// MeterProvider creates the dropped entries counter, the global one is
+	// used when nil.
+	MeterProvider metric.MeterProvider
+}
+
+// SamplingOptionsFromConfig returns the sampling options defined by the
+// `log.sampling.*` config keys, and whether sampling is enabled, or an error
+// if they cannot be decoded.
+func SamplingOptionsFromConfig() (SamplingOptions, bool, error) {
+	cfg, err := configs.GetLogSampling()
+	if err != nil {
+		return SamplingOptions{}, false, err
+	}
+	opts := SamplingOptions{
+		Interval: cfg.Interval,
+		Budget:   cfg.SamplingBudget,
+	}
+	if len(cfg.Levels) > 0 {
+		opts.Levels = make(map[slog.Level]SamplingBudget, len(cfg.Levels))
+		for level, budget := range cfg.Levels {
+			opts.Levels[parseLogLevel(level)] = budget
+		}
+	}
+	return opts, cfg.Enabled, nil
+}
+
+// sampler holds the counters shared by a sampling handler and its derived handlers.
+type sampler struct {
+	opts    SamplingOptions
+	dropped metric.Int64Counter
+
+	mu sync.Mutex
+	// root receives the summary records: the wrapped handler with the
+	// attributes of the first WithAttrs call on the created handler (like the
+	// service attributes added by the setup), without the attributes and
+	// groups of the other derived handlers.
+	root      slog.Handler
+	rootAttrs bool
+	windowEnd time.Time
+	// counts is indexed by the hash of the entry level and message, so it
+	// does not grow with the number of distinct messages.
+	counts      []int
+	droppedByLv map[slog.Level]int64
+	// timer logs the summary when the interval ends without a new entry.
+	timer  *time.Timer
+	closed bool
+}
+
+// SamplingHandler limits the number of identical entries logged per interval.
+type SamplingHandler struct {
+	h       slog.Handler
+	sampler *sampler
+	// created is set on the handler returned by NewSamplingHandler.
+	created bool
+}
+
+// NewSamplingHandler wraps h, limiting the number of identical entries (same
+// level and message) logged per interval. Errors are never dropped. Entries
+// are counted in a fixed-size table indexed by a hash of their level and
+// message, so distinct entries may rarely share a budget. When an
+// interval ends, a summary record with the number of dropped entries is
+// logged, and the dropped entries are counted by the `log.sampling.dropped`
+// OpenTelemetry counter. Close, or CloseSamplingHandlers (called by the
+// telemetry shutdown function) for all the created handlers, logs the summary
+// of the current interval and stops its timer.
+func NewSamplingHandler(h slog.Handler, opts SamplingOptions) *SamplingHandler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	mp := opts.MeterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	dropped, err := mp.Meter(samplingMeterName).Int64Counter(
+		"log.sampling.dropped",
+		metric.WithDescription("Number of log entries dropped by sampling"),
+		metric.WithUnit("{entry}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	smp := &sampler{
+		opts:        opts,
+		root:        h,
+		dropped:     dropped,
+		counts:      make([]int, samplingCounters),
+		droppedByLv: map[slog.Level]int64{},
+	}
+	samplersMu.Lock()
+	samplers = append(samplers, smp)
+	samplersMu.Unlock()
+	return &SamplingHandler{h: h, sampler: smp, created: true}
+}
+
+// Close logs the summary of the entries dropped during the current interval
+// and stops the timer, unregistering the handler from CloseSamplingHandlers.
+// Entries handled afterward are still sampled, without summary.
+func (s *SamplingHandler) Close(ctx context.Context) error {
+	samplersMu.Lock()
+	samplers = slices.DeleteFunc(samplers, func(smp *sampler) bool { return smp == s.sampler })
+	samplersMu.Unlock()
+	return s.sampler.close(ctx)
+}
+
+func (s *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.h.Enabled(ctx, level)
+}
+
+func (s *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelError {
+		return s.h.Handle(ctx, record)
+	}
+
+	keep, summary := s.sampler.sample(record.Level, record.Message, record.Time)
+	if summary != nil {
+		_ = s.sampler.summaryHandler().Handle(ctx, *summary)
+	}
+	if !keep {
+		if s.sampler.dropped != nil {
+			s.sampler.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("level", LevelName(record.Level))))
+		}
+		return nil
+	}
+	return s.h.Handle(ctx, record)
+}
+
+func (s *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h := s.h.WithAttrs(attrs)
+	if s.created {
+		s.sampler.setRootAttrs(h)
+	}
+	return &SamplingHandler{h: h, sampler: s.sampler}
+}
+
+func (s *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{h: s.h.WithGroup(name), sampler: s.sampler}
+}
+
+// counterIndex returns the index in the counter table of the entries with
+// level and msg.
+func counterIndex(level slog.Level, msg string) uint32 {
+	// inlined 32-bit FNV-1a, hash/fnv allocates
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	h = (h ^ uint32(uint8(level))) * prime32
+	for i := 0; i < len(msg); i++ {
+		h = (h ^ uint32(msg[i])) * prime32
+	}
+	return h % samplingCounters
+}
+
+// setRootAttrs makes h, derived from the root with attributes, receive the
+// summary records, unless a previous call already did.
+func (s *sampler) setRootAttrs(h slog.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.rootAttrs {
+		s.root = h
+		s.rootAttrs = true
+	}
+}
+
+func (s *sampler) summaryHandler() slog.Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root
+}
+
+func (s *sampler) budget(level slog.Level) SamplingBudget {
+	if b, ok := s.opts.Levels[level]; ok {
+		return b
+	}
+	return s.opts.Budget
+}
+
+// sample reports whether the entry is kept, and returns the summary of the
+// previous interval when it just ended with dropped entries.
+func (s *sampler) sample(level slog.Level, msg string, now time.Time) (bool, *slog.Record) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summary *slog.Record
+	if !now.Before(s.windowEnd) {
+		// the timer may not have fired yet
+		summary = s.summaryLocked(now)
+		s.stopTimerLocked()
+		clear(s.counts)
+		s.windowEnd = now.Add(s.opts.Interval)
+	}
+
+	i := counterIndex(level, msg)
+	s.counts[i]++
+	n := s.counts[i]
+
+	b := s.budget(level)
+	keep := n <= b.Initial || (b.Thereafter > 0 && (n-b.Initial)%b.Thereafter == 0)
+	if !keep {
+		s.droppedByLv[level]++
+		if s.timer == nil && !s.closed {
+			windowEnd := s.windowEnd
+			s.timer = time.AfterFunc(windowEnd.Sub(now), func() { s.endWindow(windowEnd) })
+		}
+	}
+	return keep, summary
+}
+
+// endWindow logs the summary of the interval ending at windowEnd, unless a
+// new entry already started the next one.
+func (s *sampler) endWindow(windowEnd time.Time) {
+	s.mu.Lock()
+	if !s.windowEnd.Equal(windowEnd) {
+		s.mu.Unlock()
+		return
+	}
+	s.timer = nil
+	summary := s.summaryLocked(time.Now())
+	root := s.root
+	s.mu.Unlock()
+
+	if summary != nil {
+		_ = root.Handle(context.Background(), *summary)
+	}
+}
+
+// close stops the timer and logs the summary of the current interval.
+func (s *sampler) close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.stopTimerLocked()
+	summary := s.summaryLocked(time.Now())
+	root := s.root
+	s.mu.Unlock()
+
+	if summary == nil {
+		return nil
+	}
+	return root.Handle(ctx, *summary)
+}
+
+func (s *sampler) stopTimerLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+func (s *sampler) summaryLocked(now time.Time) *slog.Record {
+	if len(s.droppedByLv) == 0 {
+		return nil
+	}
+	var total int64
+	byLevel := make([]any, 0, len(s.droppedByLv))
+	for _, level := range slices.Sorted(maps.Keys(s.droppedByLv)) {
+		n := s.droppedByLv[level]
+		total += n
+		byLevel = append(byLevel, slog.Int64(LevelName(level), n))
+	}
+	clear(s.droppedByLv)
+
+	record := slog.NewRecord(now, slog.LevelWarn, SamplingSummaryMessage, 0)
+	record.AddAttrs(
+		slog.Int64("dropped", total),
+		slog.Group("dropped_by_level", byLevel...),
+		slog.Duration("interval", s.opts.Interval),
+	)
+	return &record
+}
+
+// CloseSamplingHandlers logs the summaries of the entries dropped during the
+// current intervals by all the sampling handlers, and stops their timers.
+func CloseSamplingHandlers(ctx context.Context) error {
+	samplersMu.Lock()
+	closing := samplers
+	samplers = nil
+	samplersMu.Unlock()
+
+	var errs []error
+	for _, s := range closing {
+		if err := s.close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
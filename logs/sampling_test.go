@@ -0,0 +1,194 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func countMessages(entries []map[string]any) map[string]int {
+	counts := map[string]int{}
+	for _, e := range entries {
+		counts[e["msg"].(string)]++
+	}
+	return counts
+}
+
+// lockedBuffer is a buffer written by the sampling timers.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) messages(t *testing.T) map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return countMessages(decodeLogLines(t, bytes.NewBuffer(b.buf.Bytes())))
+}
+
+func TestSamplingHandler(t *testing.T) {
+	newLogger := func(opts SamplingOptions) (*slog.Logger, *bytes.Buffer) {
+		var buf bytes.Buffer
+		h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+		return slog.New(NewSamplingHandler(h, opts)), &buf
+	}
+
+	t.Run("keeps the first entries then every Mth", func(t *testing.T) {
+		l, buf := newLogger(SamplingOptions{Interval: time.Hour, Budget: SamplingBudget{Initial: 3, Thereafter: 5}})
+		for range 20 {
+			l.Info("hot loop")
+			l.Info("other message")
+		}
+
+		counts := countMessages(decodeLogLines(t, buf))
+		// 1, 2, 3, 8, 13, 18
+		assert.Equal(t, 6, counts["hot loop"])
+		assert.Equal(t, 6, counts["other message"])
+	})
+
+	t.Run("uses level budgets and never drops errors", func(t *testing.T) {
+		l, buf := newLogger(SamplingOptions{
+			Interval: time.Hour,
+			Budget:   SamplingBudget{Initial: 1},
+			Levels:   map[slog.Level]SamplingBudget{slog.LevelWarn: {Initial: 5}},
+		})
+		for range 10 {
+			l.Debug("debug")
+			l.Warn("warn")
+			l.Error("error")
+		}
+
+		counts := countMessages(decodeLogLines(t, buf))
+		assert.Equal(t, 1, counts["debug"])
+		assert.Equal(t, 5, counts["warn"])
+		assert.Equal(t, 10, counts["error"])
+	})
+
+	t.Run("does not grow with the distinct messages", func(t *testing.T) {
+		h := NewSamplingHandler(slog.NewJSONHandler(io.Discard, nil), SamplingOptions{Interval: time.Hour, Budget: SamplingBudget{Initial: 1}})
+		l := slog.New(h)
+		for i := range 3 * samplingCounters {
+			l.Info(fmt.Sprintf("message %d", i))
+		}
+
+		assert.Len(t, h.sampler.counts, samplingCounters)
+	})
+
+	t.Run("logs a summary of the dropped entries", func(t *testing.T) {
+		l, buf := newLogger(SamplingOptions{Interval: 20 * time.Millisecond, Budget: SamplingBudget{Initial: 1}})
+		logger := l.With("service.name", "app").With("pkg", "test")
+		for range 5 {
+			logger.Info("hot loop")
+		}
+		logger.Debug("hot loop")
+		time.Sleep(30 * time.Millisecond)
+		logger.Info("next interval")
+
+		entries := decodeLogLines(t, buf)
+		require.Len(t, entries, 4)
+		summary := entries[2]
+		assert.Equal(t, SamplingSummaryMessage, summary["msg"])
+		assert.Equal(t, float64(4), summary["dropped"])
+		assert.Equal(t, map[string]any{"info": float64(4)}, summary["dropped_by_level"])
+		assert.Equal(t, "app", summary["service.name"])
+		assert.NotContains(t, summary, "pkg")
+		assert.Equal(t, "next interval", entries[3]["msg"])
+	})
+
+	t.Run("logs the summary when logging goes quiet", func(t *testing.T) {
+		var buf lockedBuffer
+		l := slog.New(NewSamplingHandler(slog.NewJSONHandler(&buf, nil), SamplingOptions{Interval: 20 * time.Millisecond, Budget: SamplingBudget{Initial: 1}}))
+		for range 3 {
+			l.Info("hot loop")
+		}
+
+		assert.Eventually(t, func() bool {
+			return buf.messages(t)[SamplingSummaryMessage] == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("logs the pending summaries when closed", func(t *testing.T) {
+		var buf lockedBuffer
+		l := slog.New(NewSamplingHandler(slog.NewJSONHandler(&buf, nil), SamplingOptions{Interval: time.Hour, Budget: SamplingBudget{Initial: 1}}))
+		for range 3 {
+			l.Info("hot loop")
+		}
+		assert.Zero(t, buf.messages(t)[SamplingSummaryMessage])
+
+		require.NoError(t, CloseSamplingHandlers(context.Background()))
+		assert.Equal(t, map[string]int{"hot loop": 1, SamplingSummaryMessage: 1}, buf.messages(t))
+	})
+
+	t.Run("logs the pending summary and unregisters when the handler is closed", func(t *testing.T) {
+		var buf lockedBuffer
+		h := NewSamplingHandler(slog.NewJSONHandler(&buf, nil), SamplingOptions{Interval: time.Hour, Budget: SamplingBudget{Initial: 1}})
+		for range 3 {
+			slog.New(h).Info("hot loop")
+		}
+
+		require.NoError(t, h.Close(context.Background()))
+		assert.Equal(t, map[string]int{"hot loop": 1, SamplingSummaryMessage: 1}, buf.messages(t))
+		samplersMu.Lock()
+		assert.NotContains(t, samplers, h.sampler)
+		samplersMu.Unlock()
+	})
+
+	t.Run("counts the dropped entries", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		var buf bytes.Buffer
+		l := slog.New(NewSamplingHandler(slog.NewJSONHandler(&buf, nil), SamplingOptions{
+			Interval:      time.Hour,
+			Budget:        SamplingBudget{Initial: 2},
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		}))
+		for range 5 {
+			l.Info("hot loop")
+		}
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+		sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+		require.Len(t, sum.DataPoints, 1)
+		assert.Equal(t, int64(3), sum.DataPoints[0].Value)
+	})
+}
+
+func TestSamplingOptionsFromConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.SetDefault(configs.LogSamplingIntervalKey, "1s")
+	viper.Set(configs.LogSamplingEnabledKey, true)
+	viper.Set(configs.LogSamplingInitialKey, 10)
+	viper.Set(configs.LogSamplingThereafterKey, 50)
+	viper.Set(configs.LogSamplingLevelsKey, map[string]any{"debug": map[string]any{"initial": 1, "thereafter": 0}})
+
+	opts, enabled, err := SamplingOptionsFromConfig()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, time.Second, opts.Interval)
+	assert.Equal(t, SamplingBudget{Initial: 10, Thereafter: 50}, opts.Budget)
+	assert.Equal(t, map[slog.Level]SamplingBudget{slog.LevelDebug: {Initial: 1}}, opts.Levels)
+
+	viper.Set(configs.LogSamplingLevelsKey, map[string]any{"debug": map[string]any{"initial": "few"}})
+	_, _, err = SamplingOptionsFromConfig()
+	assert.ErrorContains(t, err, configs.LogSamplingLevelsKey)
+}
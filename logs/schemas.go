@@ -0,0 +1,229 @@
+package logs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ECSVersion is the Elastic Common Schema version of the `ecs` format.
+const ECSVersion = "8.11"
+
+// GCPProjectEnv is the environment variable holding the Google Cloud project
+// used to qualify trace IDs in the `gcp` format.
+const GCPProjectEnv = "GOOGLE_CLOUD_PROJECT"
+
+// traceFields defines the attributes a schema uses for the current trace and span.
+type traceFields struct {
+	traceKey   string
+	spanKey    string
+	sampledKey string
+	traceValue func(trace.TraceID) string
+}
+
+// traceHandler adds the trace and span IDs of the context to the records.
+type traceHandler struct {
+	slog.Handler
+	fields traceFields
+}
+
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		r = r.Clone()
+		traceID := sc.TraceID().String()
+		if h.fields.traceValue != nil {
+			traceID = h.fields.traceValue(sc.TraceID())
+		}
+		r.AddAttrs(slog.String(h.fields.traceKey, traceID), slog.String(h.fields.spanKey, sc.SpanID().String()))
+		if h.fields.sampledKey != "" {
+			r.AddAttrs(slog.Bool(h.fields.sampledKey, sc.IsSampled()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithAttrs(attrs), fields: h.fields}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{Handler: h.Handler.WithGroup(name), fields: h.fields}
+}
+
+// NewECSHandler creates a JSON handler following the Elastic Common Schema.
+func NewECSHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: ecsReplaceAttr,
+	})
+	return &traceHandler{
+		Handler: h.WithAttrs([]slog.Attr{slog.String("ecs.version", ECSVersion)}),
+		fields:  traceFields{traceKey: "trace.id", spanKey: "span.id"},
+	}
+}
+
+func ecsReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 1 && groups[0] == ErrorKey && a.Key == "stack" {
+		if stack, ok := a.Value.Any().([]string); ok {
+			return slog.String("stack_trace", strings.Join(stack, "\n"))
+		}
+	}
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		return slog.String("@timestamp", a.Value.Time().UTC().Format(time.RFC3339Nano))
+	case slog.LevelKey:
+		return slog.String("log.level", LevelName(levelOf(a.Value)))
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.SourceKey:
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			return slog.Group("log.origin",
+				slog.Group("file", slog.String("name", src.File), slog.Int("line", src.Line)),
+				slog.String("function", src.Function),
+			)
+		}
+	case "error":
+		if err, ok := a.Value.Any().(error); ok {
+			return Err(err)
+		}
+	case "host":
+		a.Key = "host.name"
+	}
+	return a
+}
+
+// NewGCPHandler creates a JSON handler following the Google Cloud Logging
+// structured logging fields. Trace IDs are qualified with the project of the
+// GOOGLE_CLOUD_PROJECT environment variable when set.
+func NewGCPHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: gcpReplaceAttr,
+	})
+	project := os.Getenv(GCPProjectEnv)
+	return &traceHandler{
+		Handler: h,
+		fields: traceFields{
+			traceKey:   "logging.googleapis.com/trace",
+			spanKey:    "logging.googleapis.com/spanId",
+			sampledKey: "logging.googleapis.com/trace_sampled",
+			traceValue: func(id trace.TraceID) string {
+				if project == "" {
+					return id.String()
+				}
+				return "projects/" + project + "/traces/" + id.String()
+			},
+		},
+	}
+}
+
+func gcpReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		return slog.String("severity", gcpSeverity(levelOf(a.Value)))
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.SourceKey:
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			return slog.Group("logging.googleapis.com/sourceLocation",
+				slog.String("file", src.File),
+				slog.String("line", strconv.Itoa(src.Line)),
+				slog.String("function", src.Function),
+			)
+		}
+	case "error":
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String("error", err.Error())
+		}
+	case "service.name":
+		return slog.Group("serviceContext", slog.String("service", a.Value.String()))
+	}
+	return a
+}
+
+func gcpSeverity(level slog.Level) string {
+	switch {
+	case level >= LevelFatal:
+		return "ALERT"
+	case level >= LevelPanic:
+		return "CRITICAL"
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARNING"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// NewLogfmtHandler creates a logfmt handler (`ts=... level=info caller=dir/file.go:42 msg=...`).
+func NewLogfmtHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return &traceHandler{
+		Handler: slog.NewTextHandler(w, &slog.HandlerOptions{
+			AddSource:   true,
+			Level:       level,
+			ReplaceAttr: logfmtReplaceAttr,
+		}),
+		fields: traceFields{traceKey: "trace_id", spanKey: "span_id"},
+	}
+}
+
+func logfmtReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		return slog.String("ts", a.Value.Time().UTC().Format(time.RFC3339Nano))
+	case slog.LevelKey:
+		return slog.String(slog.LevelKey, LevelName(levelOf(a.Value)))
+	case slog.SourceKey:
+		if src, ok := a.Value.Any().(*slog.Source); ok {
+			return slog.String("caller", shortSource(src))
+		}
+	case "error":
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String("err", err.Error())
+		}
+	}
+	return a
+}
+
+func levelOf(v slog.Value) slog.Level {
+	if level, ok := v.Any().(slog.Level); ok {
+		return level
+	}
+	return parseLogLevel(v.String())
+}
+
+// schemaHandler returns the handler of the vendor specific formats, or nil
+// for the other formats.
+func schemaHandler(format string, level slog.Leveler, w io.Writer) slog.Handler {
+	switch strings.ToLower(format) {
+	case configs.LogFormatECS:
+		return NewECSHandler(w, level)
+	case configs.LogFormatGCP:
+		return NewGCPHandler(w, level)
+	case configs.LogFormatLogfmt:
+		return NewLogfmtHandler(w, level)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContext(t *testing.T) context.Context {
+	t.Helper()
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func TestECSHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewLogHandler(configs.LogFormatECS, slog.LevelDebug, &buf)).With("service.name", "app", "host", "server-01")
+	l.ErrorContext(spanContext(t), "failed", "error", errors.New("boom"))
+
+	entries := decodeLogLines(t, &buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, ECSVersion, e["ecs.version"])
+	assert.NotEmpty(t, e["@timestamp"])
+	assert.Equal(t, "error", e["log.level"])
+	assert.Equal(t, "failed", e["message"])
+	assert.Equal(t, "app", e["service.name"])
+	assert.Equal(t, "server-01", e["host.name"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", e["trace.id"])
+	assert.Equal(t, "00f067aa0ba902b7", e["span.id"])
+	assert.Equal(t, map[string]any{"message": "boom", "type": "*errors.errorString"}, e["error"])
+
+	origin := e["log.origin"].(map[string]any)
+	assert.Contains(t, origin["function"], "TestECSHandler")
+	assert.Contains(t, origin["file"].(map[string]any)["name"], "schemas_test.go")
+	for _, key := range []string{"time", "level", "msg", "source"} {
+		assert.NotContains(t, e, key)
+	}
+}
+
+func TestGCPHandler(t *testing.T) {
+	t.Run("maps the Cloud Logging fields", func(t *testing.T) {
+		t.Setenv(GCPProjectEnv, "my-project")
+		var buf bytes.Buffer
+		l := slog.New(NewLogHandler(configs.LogFormatGCP, slog.LevelDebug, &buf)).With("service.name", "app")
+		l.WarnContext(spanContext(t), "slow", "error", errors.New("timeout"))
+
+		entries := decodeLogLines(t, &buf)
+		require.Len(t, entries, 1)
+		e := entries[0]
+		assert.Equal(t, "WARNING", e["severity"])
+		assert.Equal(t, "slow", e["message"])
+		assert.NotEmpty(t, e["time"])
+		assert.Equal(t, "timeout", e["error"])
+		assert.Equal(t, map[string]any{"service": "app"}, e["serviceContext"])
+		assert.Equal(t, "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736", e["logging.googleapis.com/trace"])
+		assert.Equal(t, "00f067aa0ba902b7", e["logging.googleapis.com/spanId"])
+		assert.Equal(t, true, e["logging.googleapis.com/trace_sampled"])
+
+		src := e["logging.googleapis.com/sourceLocation"].(map[string]any)
+		assert.Contains(t, src["file"], "schemas_test.go")
+		assert.IsType(t, "", src["line"])
+	})
+
+	t.Run("maps the levels to severities", func(t *testing.T) {
+		tests := map[slog.Level]string{
+			slog.LevelDebug:     "DEBUG",
+			slog.LevelInfo:      "INFO",
+			slog.LevelWarn:      "WARNING",
+			slog.LevelError:     "ERROR",
+			slog.LevelError + 4: "CRITICAL",
+		}
+		for level, want := range tests {
+			assert.Equal(t, want, gcpSeverity(level))
+		}
+	})
+
+	t.Run("omits the trace fields without a span", func(t *testing.T) {
+		var buf bytes.Buffer
+		slog.New(NewGCPHandler(&buf, slog.LevelInfo)).Info("no span")
+
+		e := decodeLogLines(t, &buf)[0]
+		assert.NotContains(t, e, "logging.googleapis.com/trace")
+	})
+}
+
+func TestLogfmtHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewLogHandler(configs.LogFormatLogfmt, slog.LevelDebug, &buf))
+	l.InfoContext(spanContext(t), "request done", "status", 200, "error", errors.New("boom"))
+
+	line := buf.String()
+	assert.Regexp(t, `^ts=\S+ level=info caller=logs/schemas_test\.go:\d+ msg="request done" status=200 err=boom `, line)
+	assert.Contains(t, line, "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7\n")
+}
+
+func TestECSHandler_StackTrace(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(NewECSHandler(&buf, slog.LevelInfo)).Error("failed", Err(WrapError(errors.New("boom"), "loading")))
+
+	e := decodeLogLines(t, &buf)[0]["error"].(map[string]any)
+	assert.Equal(t, "loading: boom", e["message"])
+	assert.Contains(t, e["stack_trace"], "TestECSHandler_StackTrace")
+	assert.NotContains(t, e, "stack")
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/eldius/initial-config-go/logs"
 	"github.com/eldius/initial-config-go/telemetry"
@@ -25,6 +26,34 @@ var (
 	ErrInvalidLogOutputConfig = errors.New("invalid log output configuration: should enable stdout or define an output file")
 )
 
+// logHandlerCloser is a log handler running timers or goroutines, like the
+// asynchronous and sampling handlers.
+type logHandlerCloser interface {
+	Close(ctx context.Context) error
+}
+
+var (
+	logHandlersMu sync.Mutex
+	// logHandlers are the handlers created by the last logs setup, closed
+	// when they are replaced.
+	logHandlers []logHandlerCloser
+)
+
+// replaceLogHandlers closes the handlers of the previous logs setup, replaced
+// by the created ones.
+func replaceLogHandlers(ctx context.Context, created []logHandlerCloser) {
+	logHandlersMu.Lock()
+	previous := logHandlers
+	logHandlers = created
+	logHandlersMu.Unlock()
+
+	for _, h := range previous {
+		if err := h.Close(ctx); err != nil {
+			slog.WarnContext(ctx, "failed to close the replaced log handler", "error", err)
+		}
+	}
+}
+
 func initLogs(ctx context.Context, appName string, options Options) error {
 	return setupLogs(ctx, appName, configs.GetLogFormat(), configs.GetLogLevel(), configs.GetLogOutputFile(), configs.GetLogToStdout(), options, configs.GetLogKeysToRedact()...)
 }
@@ -53,6 +82,28 @@ func setupLogs(ctx context.Context, appName, format, level, logOutputFile string
 		keysToRedact[i] = strings.ToLower(key)
 	}
 
+	// Fatal logs flush and shut down the telemetry before exiting, Panic logs
+	// only flush it as the panic may be recovered
+	logs.SetExitHooks(telemetry.TelemetryForceFlush, telemetry.TelemetryShutdown)
+	logs.SetPanicHooks(telemetry.TelemetryForceFlush)
+
+	logs.ConfigureLevels(level, configs.GetLogLevels())
+	levelRouter := logs.DefaultLevelRouter()
+
+	samplingOpts, sampling, err := logs.SamplingOptionsFromConfig()
+	if err != nil {
+		return fmt.Errorf("reading log sampling configuration: %w", err)
+	}
+
+	redactor := cfg.Redactor
+	if redactor == nil {
+		redactionOpts, err := logs.RedactorOptionsFromConfig()
+		if err != nil {
+			return fmt.Errorf("creating log redactor: %w", err)
+		}
+		redactor = logs.NewRedactor(keysToRedact, redactionOpts...)
+	}
+
 	if cfg.Enabled && cfg.Endpoints.Logs != "" {
 		exporter, err := logShipper(ctx, cfg.Endpoints.Logs)
 		if err != nil {
@@ -71,6 +122,7 @@ func setupLogs(ctx context.Context, appName, format, level, logOutputFile string
 
 		loggerProvider := otellog.NewLoggerProvider(
 			otellog.WithResource(res),
+			otellog.WithProcessor(telemetry.NewRedactLogProcessor(redactor)),
 			otellog.WithProcessor(otellog.NewBatchProcessor(exporter)),
 		)
 
@@ -78,39 +130,78 @@ func setupLogs(ctx context.Context, appName, format, level, logOutputFile string
 
 		telemetry.SetLoggerProvider(loggerProvider)
 
-		handler := logs.NewRedactHandler(
-			otelslog.NewHandler(
-				appName,
-				otelslog.WithLoggerProvider(loggerProvider),
-			),
-			keysToRedact,
-		)
+		var created []logHandlerCloser
+		handler := logs.NewContextHandler(logs.NewLevelRouterHandler(
+			withSampling(&created, logs.NewRedactHandlerWithRedactor(
+				withRecentLogs(otelslog.NewHandler(
+					appName,
+					otelslog.WithLoggerProvider(loggerProvider),
+				)),
+				redactor,
+			), samplingOpts, sampling),
+			levelRouter,
+		))
 		// Set the default slog logger to use the OTel bridge handler
 		slog.SetDefault(slog.New(handler))
+		replaceLogHandlers(ctx, created)
 		return nil
 	}
+	host, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
 	writer, err := logs.GetWriter(logOutputFile, stdout)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrInvalidLogOutputConfig, err)
 	}
-	h, err := logs.LogHandler(format, level, writer, keysToRedact...)
-	if err != nil {
-		return fmt.Errorf("failed to create log handler: %w", err)
+	var created []logHandlerCloser
+	h := logs.NewLogHandler(format, levelRouter.MinLevel(), writer)
+	if asyncOpts, enabled := logs.AsyncOptionsFromConfig(); enabled {
+		async, err := logs.NewAsyncHandler(h, asyncOpts)
+		if err != nil {
+			return fmt.Errorf("creating async log handler: %w", err)
+		}
+		h = async
+		created = append(created, async)
 	}
-	logger := slog.New(h)
-	host, err := os.Hostname()
-	if err != nil {
-		return fmt.Errorf("failed to get hostname: %w", err)
+	h = withRecentLogs(h)
+	if !redactor.Empty() {
+		h = logs.NewRedactHandlerWithRedactor(h, redactor)
 	}
+	logger := slog.New(logs.NewContextHandler(logs.NewLevelRouterHandler(withSampling(&created, h, samplingOpts, sampling), levelRouter)))
 
 	slog.SetDefault(logger.With(
 		slog.String("service.name", appName),
 		slog.String("host", host),
 	))
+	replaceLogHandlers(ctx, created)
 
 	return nil
 }
 
+// withSampling wraps h with the sampling handler of opts when enabled,
+// adding it to created.
+func withSampling(created *[]logHandlerCloser, h slog.Handler, opts logs.SamplingOptions, enabled bool) slog.Handler {
+	if !enabled {
+		return h
+	}
+	sampling := logs.NewSamplingHandler(h, opts)
+	*created = append(*created, sampling)
+	return sampling
+}
+
+// withRecentLogs tees the records of h into the default recent logs buffer
+// when enabled by the config.
+func withRecentLogs(h slog.Handler) slog.Handler {
+	opts, enabled := logs.RecentOptionsFromConfig()
+	if !enabled {
+		return h
+	}
+	recent := logs.NewRecentLogs(opts)
+	logs.SetDefaultRecentLogs(recent)
+	return recent.Handler(h)
+}
+
 func logShipper(ctx context.Context, logsEndpoint string) (*otlploggrpc.Exporter, error) {
 	exporter, err := otlploggrpc.New(
 		ctx,
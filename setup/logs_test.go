@@ -1,10 +1,18 @@
 package setup
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/eldius/initial-config-go/configs"
+	"github.com/eldius/initial-config-go/logs"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_setupLogs(t *testing.T) {
@@ -24,3 +32,60 @@ func Test_setupLogs(t *testing.T) {
 		assert.Nil(t, setupLogs(t.Context(), "app", configs.LogFormatJSON, configs.LogLevelDEBUG, "my-log-file-2.log", true, Options{}))
 	})
 }
+
+func Test_setupLogsReplacesHandlers(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { replaceLogHandlers(t.Context(), nil) })
+	viper.Set(configs.LogAsyncEnabledKey, true)
+	viper.Set(configs.LogSamplingEnabledKey, true)
+
+	require.NoError(t, setupLogs(t.Context(), "app", configs.LogFormatJSON, configs.LogLevelDEBUG, "", true, Options{}))
+	previous := logHandlers
+	require.Len(t, previous, 2)
+	assert.IsType(t, &logs.AsyncHandler{}, previous[0])
+	assert.IsType(t, &logs.SamplingHandler{}, previous[1])
+
+	// running the setup again closes the handlers it replaces
+	require.NoError(t, setupLogs(t.Context(), "app", configs.LogFormatJSON, configs.LogLevelDEBUG, "", true, Options{}))
+	require.Len(t, logHandlers, 2)
+	assert.NotSame(t, previous[0], logHandlers[0])
+	assert.NotSame(t, previous[1], logHandlers[1])
+}
+
+func Test_setupLogsInvalidSampling(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(configs.LogSamplingEnabledKey, true)
+	viper.Set(configs.LogSamplingLevelsKey, map[string]any{"debug": "none"})
+
+	err := setupLogs(t.Context(), "app", configs.LogFormatJSON, configs.LogLevelDEBUG, "", true, Options{})
+	assert.ErrorContains(t, err, configs.LogSamplingLevelsKey)
+}
+
+func Test_setupLogsSamplingSummaryAttrs(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(configs.LogSamplingEnabledKey, true)
+	viper.Set(configs.LogSamplingInitialKey, 1)
+	viper.Set(configs.LogSamplingIntervalKey, "1h")
+	file := filepath.Join(t.TempDir(), "app.log")
+
+	require.NoError(t, setupLogs(t.Context(), "app", configs.LogFormatJSON, configs.LogLevelDEBUG, file, false, Options{}))
+	for range 3 {
+		slog.Info("hot loop")
+	}
+	// closing the sampling handler logs the pending summary
+	replaceLogHandlers(t.Context(), nil)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var summary map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(line, &entry))
+		if entry["message"] == logs.SamplingSummaryMessage {
+			summary = entry
+		}
+	}
+	require.NotNil(t, summary)
+	assert.Equal(t, "app", summary["service.name"])
+	assert.Contains(t, summary, "host")
+}
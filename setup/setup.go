@@ -7,7 +7,6 @@ import (
 	"log"
 	"maps"
 	"net/http"
-	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
@@ -193,8 +192,7 @@ func InitSetup(ctx context.Context, appName string, opts ...OptionFunc) error {
 		// Find a home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			log.Println(err)
-			os.Exit(1)
+			return fmt.Errorf("finding home directory: %w", err)
 		}
 
 		viper.AddConfigPath(filepath.Join(home, fmt.Sprintf(".%s", appName)))
@@ -58,6 +58,23 @@ func InitTelemetry(ctx context.Context, telemetryOpts ...Option) error {
 		cfg.Debug = configs.GetTelemetryDebugEnabled()
 	}
 
+	configViews, err := configs.GetTelemetryMetricViews()
+	if err != nil {
+		return fmt.Errorf("%w: decoding %s: %w", ErrMeterInitialization, configs.TelemetryMetricsViewsKey, err)
+	}
+	cfg.Metrics.Views = append(configViews, cfg.Metrics.Views...)
+	if cfg.Metrics.CardinalityLimit == 0 {
+		cfg.Metrics.CardinalityLimit = configs.GetTelemetryMetricsCardinalityLimit()
+	}
+
+	if cfg.Redactor == nil {
+		redactionOpts, err := logs.RedactorOptionsFromConfig()
+		if err != nil {
+			return fmt.Errorf("creating telemetry redactor: %w", err)
+		}
+		cfg.Redactor = logs.NewRedactor(configs.GetLogKeysToRedact(), redactionOpts...)
+	}
+
 	l := slog.With(
 		"component", "telemetry",
 		"enabled", cfg.IsEnabled())
@@ -144,7 +161,10 @@ func tracerProvider(ctx context.Context, cfg OTELConfigs) error {
 
 	// Register the trace exporter with a TracerProvider, using a batch
 	// span processor to aggregate spans before export.
-	bsp := sdktrace.NewBatchSpanProcessor(exporter)
+	var bsp sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
+	if !cfg.Redactor.Empty() {
+		bsp = NewRedactSpanProcessor(bsp, cfg.Redactor)
+	}
 	provider := sdktrace.NewTracerProvider(
 		sdktrace.WithSampler(sdktrace.AlwaysSample()),
 		sdktrace.WithResource(defaultResources(cfg)),
@@ -165,6 +185,11 @@ func meterProvider(ctx context.Context, cfg OTELConfigs) error {
 	)
 	l.Debug("configuring metric exporter")
 
+	providerOpts, err := meterProviderOptions(cfg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMeterInitialization, err)
+	}
+
 	var opts []otlpmetricgrpc.Option
 
 	conn, err := newGrpcConnection(cfg.Endpoints.Metrics)
@@ -186,9 +211,10 @@ func meterProvider(ctx context.Context, cfg OTELConfigs) error {
 		return fmt.Errorf("%w: %w: %w", ErrMeterInitialization, ErrMetricsExporterInitialization, err)
 	}
 
-	provider := sdkmetric.NewMeterProvider(
+	provider := sdkmetric.NewMeterProvider(append(
+		providerOpts,
 		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(10*time.Second))),
-		sdkmetric.WithResource(defaultResources(cfg)))
+	)...)
 
 	SetMeterProvider(provider)
 
@@ -198,6 +224,25 @@ func meterProvider(ctx context.Context, cfg OTELConfigs) error {
 	return nil
 }
 
+// meterProviderOptions returns the meter provider options (resource, views
+// and cardinality limit) derived from the given configuration.
+func meterProviderOptions(cfg OTELConfigs) ([]sdkmetric.Option, error) {
+	views, err := metricViews(cfg.Metrics.Views)
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(defaultResources(cfg)),
+	}
+	if len(views) > 0 {
+		opts = append(opts, sdkmetric.WithView(views...))
+	}
+	if cfg.Metrics.CardinalityLimit > 0 {
+		opts = append(opts, sdkmetric.WithCardinalityLimit(cfg.Metrics.CardinalityLimit))
+	}
+	return opts, nil
+}
+
 func defaultResources(cfg OTELConfigs) *resource.Resource {
 	res := resource.NewWithAttributes(
 		semconv.SchemaURL,
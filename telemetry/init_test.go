@@ -3,7 +3,11 @@ package telemetry
 import (
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/eldius/initial-config-go/configs"
 )
 
 func TestInitTelemetry(t *testing.T) {
@@ -16,6 +20,20 @@ func TestInitTelemetry(t *testing.T) {
 		err := InitTelemetry(t.Context())
 		assert.NoError(t, err)
 	})
+
+	t.Run("invalid configured views return an error", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.TelemetryMetricsViewsKey, []map[string]any{{"instrument": "a", "buckets": "not a list"}})
+		err := InitTelemetry(t.Context(), WithOtelEnabled(false))
+		assert.ErrorIs(t, err, ErrMeterInitialization)
+	})
+
+	t.Run("merges the configured and programmatic views", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set(configs.TelemetryMetricsViewsKey, []map[string]any{{"instrument": "from.config"}})
+		require.NoError(t, InitTelemetry(t.Context(), WithOtelEnabled(false), WithMetricViews(MetricView{Instrument: "from.code"})))
+		assert.Equal(t, []MetricView{{Instrument: "from.config"}, {Instrument: "from.code"}}, cfgCache.Metrics.Views)
+	})
 }
 
 func TestTraceErrorMessages(t *testing.T) {
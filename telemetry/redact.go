@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/eldius/initial-config-go/logs"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	_ sdktrace.SpanProcessor = (*redactSpanProcessor)(nil)
+	_ sdklog.Processor       = (*redactLogProcessor)(nil)
+)
+
+type redactSpanProcessor struct {
+	next     sdktrace.SpanProcessor
+	redactor *logs.Redactor
+}
+
+// NewRedactSpanProcessor wraps next, masking span and span event attributes
+// matching the redactor rules before the span reaches it.
+func NewRedactSpanProcessor(next sdktrace.SpanProcessor, redactor *logs.Redactor) sdktrace.SpanProcessor {
+	return &redactSpanProcessor{
+		next:     next,
+		redactor: redactor,
+	}
+}
+
+func (p *redactSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *redactSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.redactor.Empty() {
+		p.next.OnEnd(s)
+		return
+	}
+	p.next.OnEnd(&redactedSpan{
+		ReadOnlySpan: s,
+		attrs:        redactAttributes(p.redactor, s.Attributes()),
+		events:       redactEvents(p.redactor, s.Events()),
+	})
+}
+
+func (p *redactSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *redactSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// redactedSpan overrides the attributes and events of a finished span.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+func (s *redactedSpan) Events() []sdktrace.Event {
+	return s.events
+}
+
+func redactEvents(r *logs.Redactor, events []sdktrace.Event) []sdktrace.Event {
+	if len(events) == 0 {
+		return events
+	}
+	result := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Attributes = redactAttributes(r, e.Attributes)
+		result[i] = e
+	}
+	return result
+}
+
+func redactAttributes(r *logs.Redactor, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if len(attrs) == 0 {
+		return attrs
+	}
+	result := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		result[i] = redactAttribute(r, kv)
+	}
+	return result
+}
+
+func redactAttribute(r *logs.Redactor, kv attribute.KeyValue) attribute.KeyValue {
+	if r.ShouldRedact(string(kv.Key)) {
+		if kv.Value.Type() == attribute.STRINGSLICE {
+			values := kv.Value.AsStringSlice()
+			for i, v := range values {
+				values[i] = r.MaskString(v)
+			}
+			return kv.Key.StringSlice(values)
+		}
+		return kv.Key.String(r.MaskString(kv.Value.Emit()))
+	}
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		if s, ok := r.RedactString(kv.Value.AsString()); ok {
+			return kv.Key.String(s)
+		}
+	case attribute.STRINGSLICE:
+		values := kv.Value.AsStringSlice()
+		changed := false
+		for i, v := range values {
+			if s, ok := r.RedactString(v); ok {
+				values[i] = s
+				changed = true
+			}
+		}
+		if changed {
+			return kv.Key.StringSlice(values)
+		}
+	}
+	return kv
+}
+
+type redactLogProcessor struct {
+	redactor *logs.Redactor
+}
+
+// NewRedactLogProcessor creates a log record processor masking attributes
+// and body values matching the redactor rules. As the SDK invokes processors
+// sequentially, it must be registered before the exporting processor.
+func NewRedactLogProcessor(redactor *logs.Redactor) sdklog.Processor {
+	return &redactLogProcessor{redactor: redactor}
+}
+
+func (p *redactLogProcessor) Enabled(_ context.Context, _ sdklog.EnabledParameters) bool {
+	return true
+}
+
+func (p *redactLogProcessor) OnEmit(_ context.Context, record *sdklog.Record) error {
+	if record == nil || p.redactor.Empty() {
+		return nil
+	}
+
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	changed := false
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		redacted, ok := redactLogKeyValue(p.redactor, nil, kv)
+		changed = changed || ok
+		attrs = append(attrs, redacted)
+		return true
+	})
+	if changed {
+		record.SetAttributes(attrs...)
+	}
+
+	if body, ok := redactLogValue(p.redactor, nil, record.Body()); ok {
+		record.SetBody(body)
+	}
+	return nil
+}
+
+func (p *redactLogProcessor) Shutdown(_ context.Context) error {
+	return nil
+}
+
+func (p *redactLogProcessor) ForceFlush(_ context.Context) error {
+	return nil
+}
+
+func redactLogKeyValue(r *logs.Redactor, path []string, kv otellog.KeyValue) (otellog.KeyValue, bool) {
+	if r.ShouldRedactPath(path, kv.Key) {
+		return otellog.String(kv.Key, r.MaskString(kv.Value.String())), true
+	}
+	v, ok := redactLogValue(r, append(path[:len(path):len(path)], kv.Key), kv.Value)
+	if !ok {
+		return kv, false
+	}
+	return otellog.KeyValue{Key: kv.Key, Value: v}, true
+}
+
+func redactLogValue(r *logs.Redactor, path []string, v otellog.Value) (otellog.Value, bool) {
+	switch v.Kind() {
+	case otellog.KindString:
+		if s, ok := r.RedactString(v.AsString()); ok {
+			return otellog.StringValue(s), true
+		}
+	case otellog.KindMap:
+		kvs := v.AsMap()
+		result := make([]otellog.KeyValue, len(kvs))
+		changed := false
+		for i, kv := range kvs {
+			redacted, ok := redactLogKeyValue(r, path, kv)
+			changed = changed || ok
+			result[i] = redacted
+		}
+		if changed {
+			return otellog.MapValue(result...), true
+		}
+	case otellog.KindSlice:
+		values := v.AsSlice()
+		result := make([]otellog.Value, len(values))
+		changed := false
+		for i, item := range values {
+			redacted, ok := redactLogValue(r, path, item)
+			changed = changed || ok
+			result[i] = redacted
+		}
+		if changed {
+			return otellog.SliceValue(result...), true
+		}
+	}
+	return v, false
+}
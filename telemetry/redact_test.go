@@ -0,0 +1,113 @@
+package telemetry
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/eldius/initial-config-go/logs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedactSpanProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	redactor := logs.NewRedactor([]string{"authorization", "args"}, logs.WithValuePatterns(regexp.MustCompile(`password=\w+`)))
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(NewRedactSpanProcessor(recorder, redactor)))
+
+	_, span := provider.Tracer("test").Start(t.Context(), "span")
+	span.SetAttributes(
+		attribute.StringSlice("http.request.header.authorization", []string{"Bearer abc"}),
+		attribute.StringSlice("args", []string{"--token", "abc"}),
+		attribute.String("db.statement", "UPDATE users SET password=abc"),
+		attribute.Int("http.status_code", 200),
+	)
+	span.AddEvent("event", trace.WithAttributes(attribute.String("authorization", "Bearer abc")))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	v, _ := attrs.Value("http.request.header.authorization")
+	assert.Equal(t, []string{"***"}, v.AsStringSlice())
+	v, _ = attrs.Value("args")
+	assert.Equal(t, []string{"***", "***"}, v.AsStringSlice())
+	v, _ = attrs.Value("db.statement")
+	assert.Equal(t, "UPDATE users SET ***", v.AsString())
+	v, _ = attrs.Value("http.status_code")
+	assert.Equal(t, int64(200), v.AsInt64())
+
+	require.Len(t, spans[0].Events(), 1)
+	eventAttrs := attribute.NewSet(spans[0].Events()[0].Attributes...)
+	v, _ = eventAttrs.Value("authorization")
+	assert.Equal(t, "***", v.AsString())
+}
+
+func TestRedactSpanProcessor_HashMask(t *testing.T) {
+	mask, err := logs.NewMaskFunc("hash", []byte("hash-key"))
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	redactor := logs.NewRedactor([]string{"args"}, logs.WithMask(mask))
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(NewRedactSpanProcessor(recorder, redactor)))
+
+	_, span := provider.Tracer("test").Start(t.Context(), "span")
+	span.SetAttributes(attribute.StringSlice("args", []string{"--token", "abc"}))
+	span.End()
+
+	attrs := attribute.NewSet(recorder.Ended()[0].Attributes()...)
+	v, _ := attrs.Value("args")
+	assert.Equal(t, []string{mask("--token"), mask("abc")}, v.AsStringSlice())
+}
+
+type recordingLogProcessor struct {
+	records []sdklog.Record
+}
+
+func (p *recordingLogProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+func (p *recordingLogProcessor) Shutdown(context.Context) error                         { return nil }
+func (p *recordingLogProcessor) ForceFlush(context.Context) error                       { return nil }
+func (p *recordingLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	p.records = append(p.records, r.Clone())
+	return nil
+}
+
+func TestRedactLogProcessor(t *testing.T) {
+	redactor := logs.NewRedactor([]string{"password"}, logs.WithValuePatterns(regexp.MustCompile(`token=\w+`)))
+	recorder := &recordingLogProcessor{}
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(NewRedactLogProcessor(redactor)),
+		sdklog.WithProcessor(recorder),
+	)
+
+	var r otellog.Record
+	r.SetBody(otellog.StringValue("calling /api?token=abc"))
+	r.AddAttributes(
+		otellog.String("password", "secret"),
+		otellog.String("user", "admin"),
+		otellog.Map("request", otellog.String("user_password", "secret"), otellog.String("url", "/?token=abc")),
+	)
+	provider.Logger("test").Emit(t.Context(), r)
+
+	require.Len(t, recorder.records, 1)
+	record := recorder.records[0]
+
+	assert.Equal(t, "calling /api?***", record.Body().AsString())
+	values := map[string]otellog.Value{}
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		values[kv.Key] = kv.Value
+		return true
+	})
+	assert.Equal(t, "***", values["password"].AsString())
+	assert.Equal(t, "admin", values["user"].AsString())
+	request := values["request"].AsMap()
+	require.Len(t, request, 2)
+	assert.Equal(t, "***", request[0].Value.AsString())
+	assert.Equal(t, "/?***", request[1].Value.AsString())
+}
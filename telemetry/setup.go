@@ -1,5 +1,13 @@
 package telemetry
 
+import (
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/eldius/initial-config-go/logs"
+)
+
+// MetricView describes a metric view (see configs.MetricView).
+type MetricView = configs.MetricView
+
 type OTELConfigs struct {
 	Service struct {
 		Name        string
@@ -11,8 +19,13 @@ type OTELConfigs struct {
 		Metrics string
 		Logs    string
 	}
-	Enabled bool
-	Debug   bool
+	Metrics struct {
+		Views            []MetricView
+		CardinalityLimit int
+	}
+	Redactor *logs.Redactor
+	Enabled  bool
+	Debug    bool
 }
 
 func (t *OTELConfigs) IsEnabled() bool {
@@ -69,3 +82,29 @@ func WithDebugEnabled(debug bool) Option {
 		cfg.Debug = debug
 	}
 }
+
+// WithMetricViews adds views to the meter provider, allowing to rename
+// instruments, filter attributes, change aggregations or drop instruments.
+// They are applied along with the views of the `telemetry.metrics.views`
+// config key.
+func WithMetricViews(views ...MetricView) Option {
+	return func(cfg *OTELConfigs) {
+		cfg.Metrics.Views = append(cfg.Metrics.Views, views...)
+	}
+}
+
+// WithMetricCardinalityLimit sets the maximum number of attribute sets
+// recorded per instrument.
+func WithMetricCardinalityLimit(limit int) Option {
+	return func(cfg *OTELConfigs) {
+		cfg.Metrics.CardinalityLimit = limit
+	}
+}
+
+// WithRedactor sets the redaction rules applied to span and log-record
+// attributes before export. Defaults to the `log.redacted_keys` configuration.
+func WithRedactor(r *logs.Redactor) Option {
+	return func(cfg *OTELConfigs) {
+		cfg.Redactor = r
+	}
+}
@@ -9,34 +9,47 @@ import (
 )
 
 func TelemetryForceFlush(ctx context.Context) error {
+	var errs []error
+	if err := logs.FlushAsyncHandlers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to force flush async logs: %w", err))
+	}
 	ps := GetProviderSet()
 	if ps == nil {
-		return nil
+		return errors.Join(errs...)
 	}
 	if ps.MeterProvider != nil {
 		if err := ps.MeterProvider.ForceFlush(ctx); err != nil {
-			return fmt.Errorf("failed to force flush metrics: %w", err)
+			errs = append(errs, fmt.Errorf("failed to force flush metrics: %w", err))
 		}
 	}
 	if ps.TracerProvider != nil {
 		if err := ps.TracerProvider.ForceFlush(ctx); err != nil {
-			return fmt.Errorf("failed to force flush traces: %w", err)
+			errs = append(errs, fmt.Errorf("failed to force flush traces: %w", err))
 		}
 	}
 	if ps.LoggerProvider != nil {
 		if err := ps.LoggerProvider.ForceFlush(ctx); err != nil {
-			return fmt.Errorf("failed to force flush logs: %w", err)
+			errs = append(errs, fmt.Errorf("failed to force flush logs: %w", err))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func TelemetryShutdown(ctx context.Context) error {
+	var errs []error
+	if err := logs.CloseSamplingHandlers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("sampling log handlers: %w", err))
+	}
+	if err := logs.CloseAsyncHandlers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("async log handlers: %w", err))
+	}
 	ps := GetProviderSet()
 	if ps == nil {
-		return logs.CloseLogFiles()
+		if err := logs.CloseLogFiles(); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
 	}
-	var errs []error
 	if ps.LoggerProvider != nil {
 		if err := ps.LoggerProvider.Shutdown(ctx); err != nil {
 			errs = append(errs, fmt.Errorf("logger provider: %w", err))
@@ -1,8 +1,15 @@
 package telemetry_test
 
 import (
+	"context"
+	"log/slog"
 	"testing"
+	"time"
 
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/eldius/initial-config-go/logs"
 	"github.com/eldius/initial-config-go/setup"
 	"github.com/eldius/initial-config-go/telemetry"
 	"github.com/stretchr/testify/assert"
@@ -23,3 +30,40 @@ func TestTelemetryShutdown(t *testing.T) {
 		t.Logf("TelemetryShutdown result: %v", err)
 	})
 }
+
+// blockingHandler blocks the writer of an async handler until released.
+type blockingHandler struct {
+	slog.Handler
+	release chan struct{}
+}
+
+func (b blockingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (b blockingHandler) Handle(context.Context, slog.Record) error {
+	<-b.release
+	return nil
+}
+
+func TestTelemetryForceFlush(t *testing.T) {
+	t.Run("flushes the providers when the async logs time out", func(t *testing.T) {
+		previous := telemetry.GetProviderSet()
+		t.Cleanup(func() { telemetry.SetProviderSet(previous) })
+		telemetry.SetProviderSet(&telemetry.ProviderSet{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tracetest.NewSpanRecorder()))})
+
+		release := make(chan struct{})
+		h, err := logs.NewAsyncHandler(blockingHandler{release: release}, logs.AsyncOptions{})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			close(release)
+			_ = logs.CloseAsyncHandlers(context.Background())
+		})
+		slog.New(h).Info("stuck")
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		err = telemetry.TelemetryForceFlush(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "failed to force flush async logs")
+		assert.ErrorContains(t, err, "failed to force flush traces")
+	})
+}
@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eldius/initial-config-go/configs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+var (
+	// ErrInvalidMetricView is returned when a metric view configuration is not valid.
+	ErrInvalidMetricView = errors.New("invalid metric view")
+)
+
+// metricViews converts the configured views into SDK views.
+func metricViews(views []MetricView) ([]sdkmetric.View, error) {
+	result := make([]sdkmetric.View, 0, len(views))
+	for i, v := range views {
+		view, err := metricView(v)
+		if err != nil {
+			return nil, fmt.Errorf("view %d: %w", i, err)
+		}
+		result = append(result, view)
+	}
+	return result, nil
+}
+
+func metricView(v MetricView) (sdkmetric.View, error) {
+	if v.Instrument == "" && v.Meter == "" {
+		return nil, fmt.Errorf("%w: instrument or meter must be defined", ErrInvalidMetricView)
+	}
+	if len(v.AllowedAttributes) > 0 && len(v.DroppedAttributes) > 0 {
+		return nil, fmt.Errorf("%w: allowed_attributes and dropped_attributes are mutually exclusive", ErrInvalidMetricView)
+	}
+	if v.Name != "" && strings.ContainsAny(v.Instrument, "*?") {
+		return nil, fmt.Errorf("%w: cannot rename instruments matched by a wildcard (%s)", ErrInvalidMetricView, v.Instrument)
+	}
+
+	agg, err := metricAggregation(v)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := sdkmetric.Stream{
+		Name:        v.Name,
+		Description: v.Description,
+		Unit:        v.Unit,
+		Aggregation: agg,
+	}
+	if len(v.AllowedAttributes) > 0 {
+		stream.AttributeFilter = attribute.NewAllowKeysFilter(attributeKeys(v.AllowedAttributes)...)
+	}
+	if len(v.DroppedAttributes) > 0 {
+		stream.AttributeFilter = attribute.NewDenyKeysFilter(attributeKeys(v.DroppedAttributes)...)
+	}
+
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{
+			Name:  v.Instrument,
+			Scope: instrumentation.Scope{Name: v.Meter},
+		},
+		stream,
+	), nil
+}
+
+func metricAggregation(v MetricView) (sdkmetric.Aggregation, error) {
+	switch strings.ToLower(v.Aggregation) {
+	case "":
+		return nil, nil
+	case configs.MetricAggregationDefault:
+		return sdkmetric.AggregationDefault{}, nil
+	case configs.MetricAggregationDrop:
+		return sdkmetric.AggregationDrop{}, nil
+	case configs.MetricAggregationSum:
+		return sdkmetric.AggregationSum{}, nil
+	case configs.MetricAggregationLastValue:
+		return sdkmetric.AggregationLastValue{}, nil
+	case configs.MetricAggregationExplicitHistogram:
+		for i := 1; i < len(v.Buckets); i++ {
+			if v.Buckets[i-1] >= v.Buckets[i] {
+				return nil, fmt.Errorf("%w: histogram buckets must be increasing: %v", ErrInvalidMetricView, v.Buckets)
+			}
+		}
+		return sdkmetric.AggregationExplicitBucketHistogram{
+			Boundaries: v.Buckets,
+			NoMinMax:   v.NoMinMax,
+		}, nil
+	case configs.MetricAggregationExponentialHistogram:
+		agg := sdkmetric.AggregationBase2ExponentialHistogram{
+			MaxSize:  160,
+			MaxScale: 20,
+			NoMinMax: v.NoMinMax,
+		}
+		if v.MaxSize > 0 {
+			agg.MaxSize = v.MaxSize
+		}
+		if v.MaxScale != 0 {
+			agg.MaxScale = v.MaxScale
+		}
+		if agg.MaxScale > 20 || agg.MaxScale < -10 {
+			return nil, fmt.Errorf("%w: exponential histogram max_scale must be between -10 and 20", ErrInvalidMetricView)
+		}
+		return agg, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown aggregation '%s'", ErrInvalidMetricView, v.Aggregation)
+	}
+}
+
+func attributeKeys(keys []string) []attribute.Key {
+	result := make([]attribute.Key, len(keys))
+	for i, k := range keys {
+		result[i] = attribute.Key(k)
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/eldius/initial-config-go/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collectMetrics(t *testing.T, views []MetricView, record func(m metric.Meter)) []metricdata.Metrics {
+	t.Helper()
+
+	cfg := NewDefaultCfg()
+	WithMetricViews(views...)(cfg)
+	opts, err := meterProviderOptions(*cfg)
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(append(opts, sdkmetric.WithReader(reader))...)
+	record(provider.Meter("test-meter"))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	var result []metricdata.Metrics
+	for _, sm := range rm.ScopeMetrics {
+		result = append(result, sm.Metrics...)
+	}
+	return result
+}
+
+func TestMetricViews(t *testing.T) {
+	t.Run("renames instrument and sets explicit buckets", func(t *testing.T) {
+		ms := collectMetrics(t, []MetricView{{
+			Instrument:  "request.duration",
+			Name:        "request.latency",
+			Aggregation: configs.MetricAggregationExplicitHistogram,
+			Buckets:     []float64{0.001, 0.01, 0.1, 1, 10},
+		}}, func(m metric.Meter) {
+			h, err := m.Float64Histogram("request.duration")
+			require.NoError(t, err)
+			h.Record(t.Context(), 0.005)
+		})
+
+		require.Len(t, ms, 1)
+		assert.Equal(t, "request.latency", ms[0].Name)
+		hist, ok := ms[0].Data.(metricdata.Histogram[float64])
+		require.True(t, ok)
+		require.Len(t, hist.DataPoints, 1)
+		assert.Equal(t, []float64{0.001, 0.01, 0.1, 1, 10}, hist.DataPoints[0].Bounds)
+	})
+
+	t.Run("uses exponential histogram aggregation", func(t *testing.T) {
+		ms := collectMetrics(t, []MetricView{{
+			Instrument:  "request.*",
+			Aggregation: configs.MetricAggregationExponentialHistogram,
+		}}, func(m metric.Meter) {
+			h, err := m.Float64Histogram("request.duration")
+			require.NoError(t, err)
+			h.Record(t.Context(), 0.005)
+		})
+
+		require.Len(t, ms, 1)
+		_, ok := ms[0].Data.(metricdata.ExponentialHistogram[float64])
+		assert.True(t, ok)
+	})
+
+	t.Run("drops attributes", func(t *testing.T) {
+		ms := collectMetrics(t, []MetricView{{
+			Instrument:        "calls",
+			DroppedAttributes: []string{"user.id"},
+		}}, func(m metric.Meter) {
+			c, err := m.Int64Counter("calls")
+			require.NoError(t, err)
+			c.Add(t.Context(), 1, metric.WithAttributes(attribute.String("user.id", "1"), attribute.String("route", "/")))
+			c.Add(t.Context(), 1, metric.WithAttributes(attribute.String("user.id", "2"), attribute.String("route", "/")))
+		})
+
+		require.Len(t, ms, 1)
+		sum, ok := ms[0].Data.(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, sum.DataPoints, 1)
+		assert.Equal(t, int64(2), sum.DataPoints[0].Value)
+		_, found := sum.DataPoints[0].Attributes.Value("user.id")
+		assert.False(t, found)
+	})
+
+	t.Run("drops instruments", func(t *testing.T) {
+		ms := collectMetrics(t, []MetricView{{
+			Meter:       "test-meter",
+			Instrument:  "noisy",
+			Aggregation: configs.MetricAggregationDrop,
+		}}, func(m metric.Meter) {
+			c, err := m.Int64Counter("noisy")
+			require.NoError(t, err)
+			c.Add(t.Context(), 1)
+		})
+
+		assert.Empty(t, ms)
+	})
+
+	t.Run("invalid views return an error", func(t *testing.T) {
+		invalid := []MetricView{
+			{},
+			{Instrument: "a", Aggregation: "unknown"},
+			{Instrument: "a", Aggregation: configs.MetricAggregationExplicitHistogram, Buckets: []float64{10, 1}},
+			{Instrument: "a", AllowedAttributes: []string{"x"}, DroppedAttributes: []string{"y"}},
+			{Instrument: "a.*", Name: "b"},
+		}
+		for _, v := range invalid {
+			_, err := metricViews([]MetricView{v})
+			assert.ErrorIs(t, err, ErrInvalidMetricView, "view: %+v", v)
+		}
+	})
+}
+
+func TestMeterProviderOptions_CardinalityLimit(t *testing.T) {
+	cfg := NewDefaultCfg()
+	WithMetricCardinalityLimit(2)(cfg)
+	opts, err := meterProviderOptions(*cfg)
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(append(opts, sdkmetric.WithReader(reader))...)
+	c, err := provider.Meter("test-meter").Int64Counter("calls")
+	require.NoError(t, err)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		c.Add(t.Context(), 1, metric.WithAttributes(attribute.String("id", id)))
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	sum := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Len(t, sum.DataPoints, 2)
+}